@@ -0,0 +1,174 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package trace
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// DefaultQueueSize is the number of records an AsyncWriter queues when
+// no size is passed to NewAsyncWriter.
+const DefaultQueueSize = 1024
+
+// ErrWriterClosed is returned by writes to a closed AsyncWriter.
+var ErrWriterClosed = errors.New("trace: write to closed AsyncWriter")
+
+// OverflowPolicy selects what an AsyncWriter does with a record written
+// while its queue is full.
+type OverflowPolicy int
+
+const (
+	// Block waits for the queue to drain, stalling the traced
+	// goroutine as a synchronous writer would, but only once the
+	// queue is full. No records are lost.
+	Block OverflowPolicy = iota
+
+	// DropNewest discards the record being written.
+	DropNewest
+
+	// DropOldest discards the oldest queued record to make room for
+	// the record being written.
+	DropOldest
+)
+
+// AsyncWriter is an io.Writer which queues trace records and writes
+// them to another io.Writer from a background goroutine, so a slow
+// destination such as a network connection or a full disk does not
+// stall the traced goroutines. Each write is one record. Assign it to
+// Writer or use it as the io.Writer of a WriterSink. An AsyncWriter
+// must be created with NewAsyncWriter.
+type AsyncWriter struct {
+	w      io.Writer
+	size   int
+	policy OverflowPolicy
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   [][]byte
+	busy    bool // a record is being written to w
+	closed  bool
+	dropped uint64
+	err     error
+	done    chan struct{}
+}
+
+// NewAsyncWriter returns an AsyncWriter writing to w which queues at
+// most size records, or DefaultQueueSize records if size is not
+// positive, and applies policy when the queue is full.
+func NewAsyncWriter(w io.Writer, size int, policy OverflowPolicy) *AsyncWriter {
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+	a := &AsyncWriter{
+		w:      w,
+		size:   size,
+		policy: policy,
+		done:   make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.mu)
+	go a.run()
+	return a
+}
+
+// Write queues a copy of p to be written to the underlying writer. It
+// only blocks when the queue is full and the policy is Block. Records
+// discarded by the other policies are counted by Dropped.
+func (a *AsyncWriter) Write(p []byte) (n int, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for !a.closed && len(a.queue) >= a.size {
+		switch a.policy {
+		case DropNewest:
+			a.dropped++
+			return len(p), nil
+		case DropOldest:
+			a.queue[0] = nil
+			a.queue = a.queue[1:]
+			a.dropped++
+		default:
+			a.cond.Wait()
+		}
+	}
+	if a.closed {
+		return 0, ErrWriterClosed
+	}
+	a.queue = append(a.queue, append([]byte(nil), p...))
+	a.cond.Broadcast()
+	return len(p), nil
+}
+
+// run writes the queued records to the underlying writer until the
+// AsyncWriter is closed and its queue is empty.
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for {
+		for len(a.queue) == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if len(a.queue) == 0 {
+			return
+		}
+		rec := a.queue[0]
+		a.queue[0] = nil
+		a.queue = a.queue[1:]
+		a.busy = true
+		a.cond.Broadcast()
+
+		a.mu.Unlock()
+		_, err := fwrite(a.w, rec)
+		a.mu.Lock()
+
+		a.busy = false
+		if err != nil && a.err == nil {
+			a.err = err
+		}
+		a.cond.Broadcast()
+	}
+}
+
+// Dropped returns the number of records discarded because the queue
+// was full.
+func (a *AsyncWriter) Dropped() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.dropped
+}
+
+// Flush waits until every record queued so far has been written, and
+// then flushes the underlying writer if it is a Flusher. It returns the
+// first error writing a record since the last Flush, if any.
+func (a *AsyncWriter) Flush() error {
+	a.mu.Lock()
+	for len(a.queue) > 0 || a.busy {
+		a.cond.Wait()
+	}
+	err := a.err
+	a.err = nil
+	a.mu.Unlock()
+
+	if f, ok := a.w.(Flusher); ok {
+		if ferr := f.Flush(); err == nil {
+			err = ferr
+		}
+	}
+	return err
+}
+
+// Close stops accepting records, waits until the queued records have
+// been written, and stops the background goroutine. The underlying
+// writer is flushed if it is a Flusher but is not closed. Writes after
+// Close return ErrWriterClosed.
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	a.closed = true
+	a.cond.Broadcast()
+	a.mu.Unlock()
+	<-a.done
+	return a.Flush()
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package trace
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+
+	"github.com/apatters/go-trace/spew"
+)
+
+// Category outputs trace records tagged with its name, so the records
+// of a subsystem can be told apart and filtered, e.g. by sinks, by the
+// gotrace command or by a Filter checking Record.Category. Its methods
+// operate identically to the package functions of the same names.
+type Category struct {
+	name string
+}
+
+// NewCategory returns a Category tagging its records with name.
+func NewCategory(name string) *Category {
+	return &Category{name: name}
+}
+
+// Name returns the name the category tags its records with.
+func (c *Category) Name() string {
+	return c.name
+}
+
+// printRecord emits a record of the category holding msg.
+func (c *Category) printRecord(level int, pc uintptr, filename string, line int, msg string) {
+	r := newRecord(level, pc, filename, line)
+	r.Category = c.name
+	r.Message = msg
+	emit(r)
+}
+
+// Print operates identically to the Print function.
+func (c *Category) Print(args ...interface{}) {
+	pc, filename, line, _ := runtime.Caller(1)
+	c.printRecord(0, pc, filename, line, message(fmt.Sprint, args))
+}
+
+// Println operates identically to the Println function.
+func (c *Category) Println(args ...interface{}) {
+	pc, filename, line, _ := runtime.Caller(1)
+	c.printRecord(0, pc, filename, line, message(fmt.Sprintln, args))
+}
+
+// Printf operates identically to the Printf function.
+func (c *Category) Printf(format string, args ...interface{}) {
+	pc, filename, line, _ := runtime.Caller(1)
+	c.printRecord(0, pc, filename, line, message(func(a ...interface{}) string {
+		return fmt.Sprintf(format, a...)
+	}, args))
+}
+
+// PrintLevel operates identically to the PrintLevel function, except
+// that a level set for the category with SetCategoryLevel replaces
+// TraceLevel and the levels of the sinks.
+func (c *Category) PrintLevel(level int, args ...interface{}) {
+	if r := levelRecord(level, c.name); r != nil {
+		r.Message = message(fmt.Sprint, args)
+		emit(r)
+	}
+}
+
+// PrintlnLevel operates identically to the PrintlnLevel function,
+// except that a level set for the category with SetCategoryLevel
+// replaces TraceLevel and the levels of the sinks.
+func (c *Category) PrintlnLevel(level int, args ...interface{}) {
+	if r := levelRecord(level, c.name); r != nil {
+		r.Message = message(fmt.Sprintln, args)
+		emit(r)
+	}
+}
+
+// PrintfLevel operates identically to the PrintfLevel function, except
+// that a level set for the category with SetCategoryLevel replaces
+// TraceLevel and the levels of the sinks.
+func (c *Category) PrintfLevel(level int, format string, args ...interface{}) {
+	if r := levelRecord(level, c.name); r != nil {
+		r.Message = message(func(a ...interface{}) string {
+			return fmt.Sprintf(format, a...)
+		}, args)
+		emit(r)
+	}
+}
+
+// Dump operates identically to the Dump function.
+func (c *Category) Dump(args ...interface{}) {
+	pc, filename, line, _ := runtime.Caller(1)
+	r := newDumpRecord(pc, filename, line, args, func(cs *spew.ConfigState, w io.Writer) {
+		cs.Fdump(w, args...)
+	})
+	r.Category = c.name
+	emit(r)
+}
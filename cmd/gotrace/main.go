@@ -0,0 +1,160 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+/*
+Command gotrace collects trace records sent by trace.NetSink, or
+written to files by a trace.WriterSink, from any number of processes,
+and prints them interleaved in time order.
+
+Usage:
+
+	gotrace [flags] [file ...]
+
+Records are read from each file named on the command line, or from the
+standard input when "-" is named, and from the connections accepted on
+the sockets given with -listen, e.g.
+
+	gotrace -listen tcp://:4242 -listen unix:///run/trace.sock
+	gotrace -f -level 2 -func 'server\.' /var/log/app/trace.json
+
+Records encoded with trace.JSONEncoder carry their time, level, source
+location and dumped values. Other lines, such as the output of
+trace.TextEncoder, are kept as messages stamped with the time they were
+received. The lines following a line starting with the -leader, such as
+the lines of a dump, are joined to its record when they arrive within
+the -window. Since records from different sources arrive out of order,
+they are held for the -window duration and output in time order. Gaps
+in the sequence numbers added by NetSink are reported as lost records.
+
+Records can be filtered by source file, function, level, category (see
+trace.Category) and a regular expression matched against the message
+and dumped values. Only JSON records carry their category, so the
+-category filter drops text records.
+
+The records already in the files are merged by time regardless of the
+-window, so each file should hold its records in time order, as the
+trace sinks write them.
+
+The -o flag selects the output format: text prints the records with
+their dumped values indented, json re-emits them as JSON lines with a
+source field added, and chrome writes instant events in the Chrome
+trace event format for chrome://tracing or Perfetto.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/apatters/go-trace"
+)
+
+// listFlag is a flag which may be given more than once.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+// regexpFlag is a flag holding an optional regular expression.
+type regexpFlag struct {
+	re *regexp.Regexp
+}
+
+func (f *regexpFlag) String() string {
+	if f.re == nil {
+		return ""
+	}
+	return f.re.String()
+}
+
+func (f *regexpFlag) Set(s string) (err error) {
+	f.re, err = regexp.Compile(s)
+	return err
+}
+
+func main() {
+	var (
+		listen listFlag
+		filter filter
+		follow = flag.Bool("f", false, "follow the files as they grow, like tail -f")
+		window = flag.Duration("window", 500*time.Millisecond, "how long records are held to sort them by time")
+		format = flag.String("o", "text", "output format: text, json or chrome")
+		leader = flag.String("leader", trace.Leader, "the `prefix` of the first line of each text record")
+	)
+	flag.Var(&listen, "listen", "accept records on a `URL` such as tcp://:4242, udp://:4242 or unix:///path (repeatable)")
+	flag.Var(&filter.file, "file", "only output records from source files matching `regexp`")
+	flag.Var(&filter.fn, "func", "only output records from functions matching `regexp`")
+	flag.Var(&filter.category, "category", "only output records of categories matching `regexp`")
+	flag.Var(&filter.match, "e", "only output records whose message or values match `regexp`")
+	flag.IntVar(&filter.level, "level", -1, "only output records at or below `level` (-1 for all)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [file ...]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	out, err := newOutput(*format, os.Stdout)
+	if err != nil {
+		fatal(err)
+	}
+	if len(listen) == 0 && flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	m := newMerger(*window, *leader, func(r *record) {
+		if filter.matchRecord(r) {
+			if err := out.write(r); err != nil {
+				fatal(err)
+			}
+		}
+	})
+	records := make(chan *record, 1024)
+	var sources sourceGroup
+	for _, u := range listen {
+		if err := sources.listen(u, records); err != nil {
+			fatal(err)
+		}
+	}
+	for _, name := range flag.Args() {
+		m.hold(name)
+		if err := sources.readFile(name, *follow, records); err != nil {
+			fatal(err)
+		}
+	}
+	go func() {
+		sources.wait()
+		close(records)
+	}()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		sources.close()
+	}()
+
+	m.run(records)
+	if err := out.close(); err != nil {
+		fatal(err)
+	}
+}
+
+// fatal reports err and exits.
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "gotrace: %v\n", err)
+	os.Exit(1)
+}
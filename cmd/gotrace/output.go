@@ -0,0 +1,190 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// textTimeFormat is the layout of record times in text output.
+const textTimeFormat = "2006-01-02T15:04:05.000000"
+
+// output writes records in one of the output formats.
+type output interface {
+	write(r *record) error
+	close() error
+}
+
+// newOutput returns the output writing records to w in format.
+func newOutput(format string, w io.Writer) (output, error) {
+	bw := bufio.NewWriter(w)
+	switch format {
+	case "text":
+		return &textOutput{w: bw}, nil
+	case "json":
+		enc := json.NewEncoder(bw)
+		enc.SetEscapeHTML(false)
+		return &jsonOutput{w: bw, enc: enc}, nil
+	case "chrome":
+		return &chromeOutput{w: bw, pids: make(map[string]int)}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// textOutput writes each record on a line followed by its values
+// indented on the following lines.
+type textOutput struct {
+	w *bufio.Writer
+}
+
+func (o *textOutput) write(r *record) error {
+	fmt.Fprintf(o.w, "%s [%s]", r.Time.Format(textTimeFormat), r.Source)
+	if r.Level > 0 {
+		fmt.Fprintf(o.w, " L%d", r.Level)
+	}
+	if r.Category != "" {
+		fmt.Fprintf(o.w, " (%s)", r.Category)
+	}
+	if r.File != "" {
+		fmt.Fprintf(o.w, " %s:%d", r.File, r.Line)
+	}
+	if r.Func != "" {
+		fmt.Fprintf(o.w, " %s", r.Func)
+	}
+	if r.Message != "" {
+		msg := strings.Replace(r.Message, "\n", "\n\t", -1)
+		fmt.Fprintf(o.w, " %s", msg)
+	}
+	_ = o.w.WriteByte('\n')
+	for _, v := range r.Values {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, v, "\t", "  "); err != nil {
+			buf.Reset()
+			buf.Write(v)
+		}
+		fmt.Fprintf(o.w, "\t%s\n", buf.Bytes())
+	}
+	return o.w.Flush()
+}
+
+func (o *textOutput) close() error {
+	return o.w.Flush()
+}
+
+// jsonOutput writes each record as a JSON object on a line of its own.
+type jsonOutput struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (o *jsonOutput) write(r *record) error {
+	if err := o.enc.Encode(r); err != nil {
+		return err
+	}
+	return o.w.Flush()
+}
+
+func (o *jsonOutput) close() error {
+	return o.w.Flush()
+}
+
+// chromeEvent is an event in the Chrome trace event format.
+type chromeEvent struct {
+	Name  string                 `json:"name"`
+	Cat   string                 `json:"cat,omitempty"`
+	Phase string                 `json:"ph"`
+	Scope string                 `json:"s,omitempty"`
+	TS    int64                  `json:"ts"`
+	PID   int                    `json:"pid"`
+	TID   int                    `json:"tid"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// chromeOutput writes records as instant events in the JSON array
+// form of the Chrome trace event format, with a process for each
+// source.
+type chromeOutput struct {
+	w      *bufio.Writer
+	pids   map[string]int
+	events int
+}
+
+func (o *chromeOutput) write(r *record) error {
+	pid, ok := o.pids[r.Source]
+	if !ok {
+		pid = len(o.pids) + 1
+		o.pids[r.Source] = pid
+		if err := o.event(chromeEvent{
+			Name:  "process_name",
+			Phase: "M",
+			PID:   pid,
+			Args:  map[string]interface{}{"name": r.Source},
+		}); err != nil {
+			return err
+		}
+	}
+	name := r.Message
+	if name == "" {
+		name = r.Func
+	}
+	args := map[string]interface{}{"level": r.Level}
+	if r.File != "" {
+		args["location"] = fmt.Sprintf("%s:%d", r.File, r.Line)
+	}
+	if r.Func != "" {
+		args["func"] = r.Func
+	}
+	if r.Seq != 0 {
+		args["seq"] = r.Seq
+	}
+	if len(r.Values) > 0 {
+		args["values"] = r.Values
+	}
+	cat := r.Category
+	if cat == "" {
+		cat = "trace"
+	}
+	if err := o.event(chromeEvent{
+		Name:  name,
+		Cat:   cat,
+		Phase: "i",
+		Scope: "p",
+		TS:    r.Time.UnixNano() / 1000,
+		PID:   pid,
+		Args:  args,
+	}); err != nil {
+		return err
+	}
+	return o.w.Flush()
+}
+
+// event writes e as the next element of the event array.
+func (o *chromeOutput) event(e chromeEvent) error {
+	p, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if o.events == 0 {
+		sep = "[\n"
+	}
+	o.events++
+	_, _ = o.w.WriteString(sep)
+	_, err = o.w.Write(p)
+	return err
+}
+
+func (o *chromeOutput) close() error {
+	if o.events == 0 {
+		_, _ = o.w.WriteString("[")
+	}
+	_, _ = o.w.WriteString("\n]\n")
+	return o.w.Flush()
+}
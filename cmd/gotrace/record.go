@@ -0,0 +1,232 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package main
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// record is a trace record as encoded by trace.JSONEncoder, with the
+// source it was received from.
+type record struct {
+	Source   string            `json:"source"`
+	Seq      uint64            `json:"seq,omitempty"`
+	Time     time.Time         `json:"time"`
+	Level    int               `json:"level"`
+	File     string            `json:"file,omitempty"`
+	Line     int               `json:"line,omitempty"`
+	Func     string            `json:"func,omitempty"`
+	Category string            `json:"category,omitempty"`
+	Message  string            `json:"msg,omitempty"`
+	Values   []json.RawMessage `json:"values,omitempty"`
+
+	order uint64 // the arrival order, to keep the sort stable
+	text  bool   // the record is a line of text rather than JSON
+	eof   bool   // the record only marks the end of the existing lines of its source
+}
+
+// parseRecord returns the record encoded on line, or a record holding
+// line as its message if it is not a JSON record.
+func parseRecord(source string, line []byte, now time.Time) *record {
+	r := &record{}
+	if err := json.Unmarshal(line, r); err != nil || r.Time.IsZero() {
+		r = &record{Time: now, Message: strings.TrimRight(string(line), "\r\n"), text: true}
+	}
+	r.Source = source
+	return r
+}
+
+// isLeaderLine reports whether the text line starts with leader, after
+// any sequence number added by trace.NetSink, and so starts a record
+// written by trace.TextEncoder. Every line starts a record when leader
+// is empty.
+func isLeaderLine(line, leader string) bool {
+	if leader == "" {
+		return true
+	}
+	if i := strings.IndexFunc(line, func(r rune) bool { return r < '0' || r > '9' }); i > 0 && line[i] == ' ' {
+		line = line[i+1:]
+	}
+	return strings.HasPrefix(line, leader)
+}
+
+// filter holds the conditions records must meet to be output.
+type filter struct {
+	file     regexpFlag
+	fn       regexpFlag
+	category regexpFlag
+	match    regexpFlag
+	level    int
+}
+
+// matchRecord reports whether r meets the conditions of f.
+func (f *filter) matchRecord(r *record) bool {
+	if f.level >= 0 && r.Level > f.level {
+		return false
+	}
+	if !matchString(f.file.re, r.File) || !matchString(f.fn.re, r.Func) ||
+		!matchString(f.category.re, r.Category) {
+		return false
+	}
+	if f.match.re == nil || f.match.re.MatchString(r.Message) {
+		return true
+	}
+	for _, v := range r.Values {
+		if f.match.re.Match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchString reports whether s matches re, which matches everything
+// when nil.
+func matchString(re *regexp.Regexp, s string) bool {
+	return re == nil || re.MatchString(s)
+}
+
+// recordHeap orders records by time, and then by arrival.
+type recordHeap []*record
+
+func (h recordHeap) Len() int { return len(h) }
+
+func (h recordHeap) Less(i, j int) bool {
+	if !h[i].Time.Equal(h[j].Time) {
+		return h[i].Time.Before(h[j].Time)
+	}
+	return h[i].order < h[j].order
+}
+
+func (h recordHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *recordHeap) Push(x interface{}) { *h = append(*h, x.(*record)) }
+
+func (h *recordHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	old[len(old)-1] = nil
+	*h = old[:len(old)-1]
+	return r
+}
+
+// merger holds records for a window of time to output them in time
+// order, and reports gaps in the sequence numbers of each source. The
+// text lines following a line starting with leader, such as the lines
+// of a dump written by trace.TextEncoder, are joined to its record when
+// they arrive within the window.
+//
+// The records already written to files are read much faster than the
+// window, and their times are long past, so the sources registered with
+// hold are merged by time instead: no record is output until every held
+// source has been read past its time.
+type merger struct {
+	window   time.Duration
+	leader   string
+	emit     func(r *record)
+	pending  recordHeap
+	order    uint64
+	seqs     map[string]uint64
+	lastText map[string]*record   // the last text record held for each source
+	held     map[string]time.Time // the time each held source has been read to
+}
+
+// newMerger returns a merger passing the records to emit in time order.
+func newMerger(window time.Duration, leader string, emit func(r *record)) *merger {
+	return &merger{
+		window:   window,
+		leader:   leader,
+		emit:     emit,
+		seqs:     make(map[string]uint64),
+		lastText: make(map[string]*record),
+		held:     make(map[string]time.Time),
+	}
+}
+
+// hold holds the records of every source until source has been read to
+// their time, or to its end.
+func (m *merger) hold(source string) {
+	m.held[source] = time.Time{}
+}
+
+// add holds r until its window has passed, first adding a record noting
+// any records lost from its source. Text lines continuing the record of
+// the previous line are joined to it instead.
+func (m *merger) add(r *record) {
+	if r.eof {
+		delete(m.held, r.Source)
+		return
+	}
+	if t, ok := m.held[r.Source]; ok && r.Time.After(t) {
+		m.held[r.Source] = r.Time
+	}
+	if r.text {
+		last := m.lastText[r.Source]
+		if last != nil && !isLeaderLine(r.Message, m.leader) {
+			last.Message += "\n" + r.Message
+			return
+		}
+		m.lastText[r.Source] = r
+	} else {
+		delete(m.lastText, r.Source)
+	}
+	if r.Seq != 0 {
+		if last, ok := m.seqs[r.Source]; ok && r.Seq > last+1 {
+			m.push(&record{
+				Source:  r.Source,
+				Time:    r.Time,
+				Message: fmt.Sprintf("%d records lost", r.Seq-last-1),
+			})
+		}
+		m.seqs[r.Source] = r.Seq
+	}
+	m.push(r)
+}
+
+func (m *merger) push(r *record) {
+	m.order++
+	r.order = m.order
+	heap.Push(&m.pending, r)
+}
+
+// flush outputs the records held since before cutoff, and which every
+// held source has been read past.
+func (m *merger) flush(cutoff time.Time) {
+	for _, t := range m.held {
+		if t.Add(-time.Nanosecond).Before(cutoff) {
+			cutoff = t.Add(-time.Nanosecond)
+		}
+	}
+	for len(m.pending) > 0 && !m.pending[0].Time.After(cutoff) {
+		r := heap.Pop(&m.pending).(*record)
+		if m.lastText[r.Source] == r {
+			delete(m.lastText, r.Source)
+		}
+		m.emit(r)
+	}
+}
+
+// run outputs the records received on records in time order until it
+// is closed, and then outputs the records still held.
+func (m *merger) run(records <-chan *record) {
+	tick := time.NewTicker(m.window/2 + time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case r, ok := <-records:
+			if !ok {
+				m.flush(time.Unix(1<<62, 0))
+				return
+			}
+			m.add(r)
+		case now := <-tick.C:
+			m.flush(now.Add(-m.window))
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRecord(t *testing.T) {
+	now := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	r := parseRecord("app", []byte(`{"seq":7,"time":"2019-01-02T03:04:00Z","level":2,"file":"main.go",`+
+		`"line":12,"func":"main.main","category":"net","msg":"hello","values":[{"a":1}]}`+"\n"), now)
+	assert.Equal(t, "app", r.Source)
+	assert.Equal(t, uint64(7), r.Seq)
+	assert.True(t, r.Time.Equal(time.Date(2019, 1, 2, 3, 4, 0, 0, time.UTC)))
+	assert.Equal(t, 2, r.Level)
+	assert.Equal(t, "main.go", r.File)
+	assert.Equal(t, 12, r.Line)
+	assert.Equal(t, "main.main", r.Func)
+	assert.Equal(t, "net", r.Category)
+	assert.Equal(t, "hello", r.Message)
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`{"a":1}`)}, r.Values)
+	assert.False(t, r.text)
+
+	// Lines which are not JSON records, or lack a time, are kept as
+	// text stamped with the time they were received.
+	for _, line := range []string{"### main.go:12 hello\r\n", `{"msg":"no time"}`, "{"} {
+		r = parseRecord("app", []byte(line), now)
+		assert.Equal(t, "app", r.Source)
+		assert.True(t, r.Time.Equal(now))
+		assert.Equal(t, regexp.MustCompile(`\r?\n$`).ReplaceAllString(line, ""), r.Message)
+		assert.True(t, r.text)
+	}
+}
+
+func TestIsLeaderLine(t *testing.T) {
+	assert.True(t, isLeaderLine("### main.go:12 hello", "### "))
+	assert.True(t, isLeaderLine("42 ### main.go:12", "### "))
+	assert.False(t, isLeaderLine("\t(int) 1", "### "))
+	assert.False(t, isLeaderLine("42", "### "))
+	assert.True(t, isLeaderLine("\t(int) 1", ""))
+}
+
+func TestFilter(t *testing.T) {
+	r := &record{
+		Level:    2,
+		File:     "server.go",
+		Func:     "main.(*server).serve",
+		Category: "net",
+		Message:  "accepted",
+		Values:   []json.RawMessage{json.RawMessage(`{"peer":"10.0.0.1"}`)},
+	}
+	re := func(s string) regexpFlag {
+		return regexpFlag{regexp.MustCompile(s)}
+	}
+	for _, tc := range []struct {
+		f    filter
+		want bool
+	}{
+		{filter{level: -1}, true},
+		{filter{level: 2}, true},
+		{filter{level: 1}, false},
+		{filter{level: -1, file: re(`^server\.go$`)}, true},
+		{filter{level: -1, file: re(`client`)}, false},
+		{filter{level: -1, fn: re(`server\)\.serve`)}, true},
+		{filter{level: -1, fn: re(`^main\.main$`)}, false},
+		{filter{level: -1, category: re(`^net$`)}, true},
+		{filter{level: -1, category: re(`^db$`)}, false},
+		{filter{level: -1, match: re(`accept`)}, true},
+		{filter{level: -1, match: re(`10\.0\.0\.1`)}, true},
+		{filter{level: -1, match: re(`refused`)}, false},
+	} {
+		assert.Equal(t, tc.want, tc.f.matchRecord(r), "%+v", tc.f)
+	}
+}
+
+func TestMerger(t *testing.T) {
+	base := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(ms int) time.Time {
+		return base.Add(time.Duration(ms) * time.Millisecond)
+	}
+	var out []string
+	m := newMerger(time.Second, "### ", func(r *record) {
+		out = append(out, r.Source+": "+r.Message)
+	})
+
+	// Records are output in time order, with gaps in the sequence
+	// numbers of each source reported as lost records.
+	m.add(&record{Source: "a", Seq: 1, Time: at(30), Message: "a1"})
+	m.add(&record{Source: "b", Seq: 1, Time: at(10), Message: "b1"})
+	m.add(&record{Source: "a", Seq: 4, Time: at(40), Message: "a4"})
+	m.add(&record{Source: "b", Seq: 2, Time: at(20), Message: "b2"})
+	m.flush(at(35))
+	assert.Equal(t, []string{"b: b1", "b: b2", "a: a1"}, out)
+	m.flush(at(100))
+	assert.Equal(t, []string{"b: b1", "b: b2", "a: a1", "a: 2 records lost", "a: a4"}, out)
+
+	// Text lines continuing a record are joined to it.
+	out = nil
+	m.add(&record{Source: "a", Time: at(200), Message: "### main.go:12", text: true})
+	m.add(&record{Source: "b", Time: at(201), Message: "### other.go:3 hi", text: true})
+	m.add(&record{Source: "a", Time: at(202), Message: "([]int) (len=1 cap=1) {", text: true})
+	m.add(&record{Source: "a", Time: at(203), Message: "\t(int) 1", text: true})
+	m.add(&record{Source: "a", Time: at(204), Message: "}", text: true})
+	m.add(&record{Source: "a", Time: at(205), Message: "### main.go:13 next", text: true})
+	m.flush(at(1000))
+	assert.Equal(t, []string{
+		"a: ### main.go:12\n([]int) (len=1 cap=1) {\n\t(int) 1\n}",
+		"b: ### other.go:3 hi",
+		"a: ### main.go:13 next",
+	}, out)
+}
+
+func TestMergerHold(t *testing.T) {
+	base := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(ms int) time.Time {
+		return base.Add(time.Duration(ms) * time.Millisecond)
+	}
+	var out []string
+	m := newMerger(time.Second, "### ", func(r *record) {
+		out = append(out, r.Source+": "+r.Message)
+	})
+
+	// The records of files, whose times are long past, are only output
+	// once every file has been read past them.
+	m.hold("a")
+	m.hold("b")
+	m.add(&record{Source: "a", Time: at(10), Message: "a1"})
+	m.add(&record{Source: "a", Time: at(30), Message: "a2"})
+	m.add(&record{Source: "a", eof: true})
+	m.flush(at(1000))
+	assert.Empty(t, out)
+	m.add(&record{Source: "b", Time: at(20), Message: "b1"})
+	m.flush(at(1000))
+	assert.Equal(t, []string{"a: a1"}, out)
+	m.add(&record{Source: "b", Time: at(40), Message: "b2"})
+	m.flush(at(1000))
+	assert.Equal(t, []string{"a: a1", "b: b1", "a: a2"}, out)
+	m.add(&record{Source: "b", eof: true})
+	m.flush(at(1000))
+	assert.Equal(t, []string{"a: a1", "b: b1", "a: a2", "b: b2"}, out)
+}
+
+func TestMergerRun(t *testing.T) {
+	var out []string
+	m := newMerger(time.Millisecond, "### ", func(r *record) {
+		out = append(out, r.Message)
+	})
+	records := make(chan *record, 3)
+	now := time.Now()
+	records <- &record{Time: now.Add(2 * time.Hour), Message: "second"}
+	records <- &record{Time: now.Add(time.Hour), Message: "first"}
+	close(records)
+	m.run(records)
+	assert.Equal(t, []string{"first", "second"}, out)
+}
@@ -0,0 +1,220 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often followed files are checked for growth.
+const pollInterval = 200 * time.Millisecond
+
+// sourceGroup tracks the listeners, connections and files records are
+// read from.
+type sourceGroup struct {
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	closers map[io.Closer]bool
+	closed  bool
+	done    chan struct{}
+	conns   int
+}
+
+// track adds c to the sources closed by close, or closes c and returns
+// false if close has already been called.
+func (g *sourceGroup) track(c io.Closer) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		_ = c.Close()
+		return false
+	}
+	if g.closers == nil {
+		g.closers = make(map[io.Closer]bool)
+		g.done = make(chan struct{})
+	}
+	g.closers[c] = true
+	return true
+}
+
+// untrack closes c and removes it from the sources.
+func (g *sourceGroup) untrack(c io.Closer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.closers, c)
+	_ = c.Close()
+}
+
+// close stops reading from every source.
+func (g *sourceGroup) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.closed {
+		return
+	}
+	g.closed = true
+	if g.done != nil {
+		close(g.done)
+	}
+	for c := range g.closers {
+		_ = c.Close()
+	}
+}
+
+// wait waits until every source has been read or closed.
+func (g *sourceGroup) wait() {
+	g.wg.Wait()
+}
+
+// listen accepts records on the socket named by rawURL, e.g.
+// tcp://:4242, udp://127.0.0.1:4242, unix:///run/trace.sock or
+// unixgram:///run/trace.sock.
+func (g *sourceGroup) listen(rawURL string, records chan<- *record) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	addr := u.Host
+	if u.Scheme == "unix" || u.Scheme == "unixgram" {
+		addr = u.Path
+	}
+	switch u.Scheme {
+	case "tcp", "tcp4", "tcp6", "unix":
+		ln, err := net.Listen(u.Scheme, addr)
+		if err != nil {
+			return err
+		}
+		if !g.track(ln) {
+			return nil
+		}
+		g.wg.Add(1)
+		go g.accept(ln, records)
+	case "udp", "udp4", "udp6", "unixgram":
+		pc, err := net.ListenPacket(u.Scheme, addr)
+		if err != nil {
+			return err
+		}
+		if !g.track(pc) {
+			return nil
+		}
+		g.wg.Add(1)
+		go g.readPackets(pc, records)
+	default:
+		return fmt.Errorf("unsupported network %q in %s", u.Scheme, rawURL)
+	}
+	return nil
+}
+
+// accept reads records from each connection accepted on ln.
+func (g *sourceGroup) accept(ln net.Listener, records chan<- *record) {
+	defer g.wg.Done()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		if !g.track(conn) {
+			return
+		}
+		g.mu.Lock()
+		g.conns++
+		source := fmt.Sprintf("%s#%d", conn.RemoteAddr(), g.conns)
+		g.mu.Unlock()
+		g.wg.Add(1)
+		go func() {
+			defer g.wg.Done()
+			defer g.untrack(conn)
+			g.readLines(source, conn, false, records)
+		}()
+	}
+}
+
+// readPackets reads records from the datagrams received on pc, each
+// holding one or more lines.
+func (g *sourceGroup) readPackets(pc net.PacketConn, records chan<- *record) {
+	defer g.wg.Done()
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		source := pc.LocalAddr().String()
+		if addr != nil && addr.String() != "" {
+			source = addr.String()
+		}
+		now := time.Now()
+		for _, line := range bytes.Split(bytes.TrimRight(buf[:n], "\n"), []byte("\n")) {
+			records <- parseRecord(source, line, now)
+		}
+	}
+}
+
+// readFile reads records from the file name, or the standard input if
+// name is "-", continuing as the file grows when follow is set.
+func (g *sourceGroup) readFile(name string, follow bool, records chan<- *record) error {
+	f := os.Stdin
+	if name != "-" {
+		var err error
+		if f, err = os.Open(name); err != nil {
+			return err
+		}
+	}
+	if !g.track(f) {
+		return nil
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		defer g.untrack(f)
+		g.readLines(name, f, follow && name != "-", records)
+	}()
+	return nil
+}
+
+// readLines reads a record from each line of r, followed by a record
+// marking the end of the lines r held when it was first read to its
+// end. When follow is set, reaching the end of r waits for more lines
+// until the group is closed.
+func (g *sourceGroup) readLines(source string, r io.Reader, follow bool, records chan<- *record) {
+	br := bufio.NewReader(r)
+	var partial []byte
+	atEOF := false
+	for {
+		line, err := br.ReadBytes('\n')
+		partial = append(partial, line...)
+		if err == nil {
+			records <- parseRecord(source, partial, time.Now())
+			partial = nil
+			continue
+		}
+		if err != io.EOF || !follow {
+			break
+		}
+		if !atEOF {
+			records <- &record{Source: source, eof: true}
+			atEOF = true
+		}
+		select {
+		case <-g.done:
+			return
+		case <-time.After(pollInterval):
+		}
+	}
+	if len(partial) > 0 {
+		records <- parseRecord(source, partial, time.Now())
+	}
+	if !atEOF {
+		records <- &record{Source: source, eof: true}
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package trace
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/apatters/go-trace/spew"
+)
+
+// ColorMode controls whether trace output is colored.
+type ColorMode int
+
+const (
+	// ColorAuto colors output only when Writer is a terminal and
+	// the NO_COLOR environment variable is not set. Both are
+	// checked when a file is first written to.
+	ColorAuto ColorMode = iota
+
+	// ColorAlways colors output regardless of Writer.
+	ColorAlways
+
+	// ColorNever disables colored output.
+	ColorNever
+)
+
+// Theme holds the ANSI escape sequences used to color trace output.
+// An empty sequence leaves that part of the output uncolored.
+type Theme struct {
+	Leader   string     // the Leader string
+	FileLine string     // the source file name and line number
+	Dump     spew.Theme // values output by the Dump functions
+}
+
+var (
+	// Color controls whether trace output is colored.
+	Color = ColorAuto
+
+	// ColorTheme holds the colors used when output is colored.
+	ColorTheme = Theme{
+		Leader:   "\x1b[1m",
+		FileLine: "\x1b[1;34m",
+		Dump:     spew.DefaultTheme,
+	}
+)
+
+// useColor returns whether output to w should be colored according
+// to Color. Only files are colored automatically, and since useColor
+// is called for every record, whether a file is a terminal is decided
+// once, along with the environment, and cached.
+func useColor(w io.Writer) bool {
+	switch Color {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	if c, ok := colorFile.Load().(colorDecision); ok && c.f == f {
+		return c.color
+	}
+	color := os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb" && isTerminal(f)
+	colorFile.Store(colorDecision{f, color})
+	return color
+}
+
+// colorDecision records whether output to a file is colored
+// automatically.
+type colorDecision struct {
+	f     *os.File
+	color bool
+}
+
+// colorFile holds the colorDecision for the last file passed to
+// useColor.
+var colorFile atomic.Value
+
+// isTerminal returns whether f is a terminal, or at least a character
+// device, which is good enough to decide whether to color output.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps s in the passed color escape sequence, if any.
+func colorize(color string, s string) string {
+	if color == "" {
+		return s
+	}
+	return color + s + spew.ColorReset
+}
+
+// colorConfig returns the spew configuration to use for colored
+// dumps, which is SpewCS with ColorTheme.Dump added when SpewCS does
+// not already have a theme.
+func colorConfig() *spew.ConfigState {
+	if SpewCS.Theme != nil {
+		return SpewCS
+	}
+	cs := *SpewCS
+	cs.Theme = &ColorTheme.Dump
+	return &cs
+}
+
+// dumpConfig returns the spew configuration to use for dumping to
+// Writer, which is colorConfig when output is colored and SpewCS
+// otherwise.
+func dumpConfig() *spew.ConfigState {
+	if !useColor(Writer) {
+		return SpewCS
+	}
+	return colorConfig()
+}
@@ -76,13 +76,43 @@ func Example() {
 	// (spew.ConfigState) {
 	// 	Indent: (string) (len=1) "\t",
 	// 	MaxDepth: (int) 0,
+	// 	MaxStringLen: (int) 0,
+	// 	MaxElements: (int) 0,
+	// 	MaxHexdumpBytes: (int) 0,
+	// 	MaxOutputBytes: (int) 0,
 	// 	DisableMethods: (bool) true,
 	// 	DisablePointerMethods: (bool) false,
 	// 	DisablePointerAddresses: (bool) false,
 	// 	DisableCapacities: (bool) false,
 	// 	ContinueOnMethod: (bool) false,
 	// 	SortKeys: (bool) true,
-	// 	SpewKeys: (bool) true
+	// 	SpewKeys: (bool) true,
+	// 	IncludeFields: ([]string) <nil>,
+	// 	ExcludeFields: ([]string) <nil>,
+	// 	RedactFields: ([]string) (len=10 cap=10) {
+	// 		(string) (len=8) "password",
+	// 		(string) (len=9) "passwords",
+	// 		(string) (len=6) "passwd",
+	// 		(string) (len=6) "secret",
+	// 		(string) (len=7) "secrets",
+	// 		(string) (len=5) "token",
+	// 		(string) (len=6) "apikey",
+	// 		(string) (len=7) "api_key",
+	// 		(string) (len=10) "credential",
+	// 		(string) (len=11) "credentials"
+	// 	},
+	// 	Redactor: (spew.Redactor) <nil>,
+	// 	Renderers: (spew.Renderer) 127,
+	// 	Compact: (bool) false,
+	// 	TypeAliases: (map[string]string) <nil>,
+	// 	ShowSharing: (bool) false,
+	// 	TypeNames: (spew.TypeNameMode) 0,
+	// 	ByteFormat: (spew.ByteFormat) 0,
+	// 	ByteArrayFormat: (spew.ByteFormat) 0,
+	// 	ByteWidth: (int) 0,
+	// 	Theme: (*spew.Theme)(<nil>),
+	// 	Format: (spew.DumpFormat) 0,
+	// 	AnnotateTypes: (bool) false
 	// }
 	// 	* example_test.go:46
 }
@@ -0,0 +1,390 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package trace
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// backupTimeFormat is the layout of the timestamp added to the
+	// names of rotated files. It sorts in time order.
+	backupTimeFormat = "20060102T150405.000000000"
+
+	// compressSuffix is added to the names of compressed backups.
+	compressSuffix = ".gz"
+)
+
+// FileSink is an io.Writer which appends trace output to a file and
+// rotates the file when it grows too large or gets too old. Assign it
+// to Writer to send trace output to the file. The file is opened on
+// the first write.
+//
+// Rotated files are renamed to the file name followed by a timestamp,
+// e.g. trace.log.20190102T030405.000000000, and optionally compressed
+// with gzip. The fields must not be changed once the sink is in use.
+type FileSink struct {
+	// Filename is the file trace output is appended to.
+	Filename string
+
+	// MaxSize is the size in bytes at which the file is rotated.
+	// Zero disables size based rotation.
+	MaxSize int64
+
+	// RotateEvery is the interval at which the file is rotated,
+	// e.g. 24 * time.Hour. Intervals start at multiples of
+	// RotateEvery since the zero time, so daily rotation happens at
+	// midnight UTC. Zero disables time based rotation.
+	RotateEvery time.Duration
+
+	// MaxBackups is the number of rotated files kept. The oldest are
+	// removed first. Zero keeps all rotated files.
+	MaxBackups int
+
+	// Compress enables gzip compression of rotated files. Files are
+	// compressed, and old backups then removed, in the background so
+	// writes are not held up.
+	Compress bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	interval time.Time
+
+	// bgMu serializes the background compression of rotated files,
+	// which compressing counts.
+	bgMu        sync.Mutex
+	compressing sync.WaitGroup
+}
+
+// NewFileSink returns a FileSink appending to filename which is never
+// rotated. Set the fields of the returned sink to enable rotation.
+func NewFileSink(filename string) *FileSink {
+	return &FileSink{Filename: filename}
+}
+
+// FileSinkFromEnv returns a FileSink configured from the environment,
+// or nil if TRACE_FILE is not set. The variables are:
+//
+//	TRACE_FILE              the file name
+//	TRACE_FILE_MAX_SIZE     MaxSize in bytes, with an optional K, M or G suffix
+//	TRACE_FILE_ROTATE_EVERY RotateEvery as a duration, e.g. 24h
+//	TRACE_FILE_MAX_BACKUPS  MaxBackups
+//	TRACE_FILE_COMPRESS     Compress as a boolean, e.g. true or 1
+//
+// An error is returned if any variable is malformed.
+func FileSinkFromEnv() (*FileSink, error) {
+	filename := os.Getenv("TRACE_FILE")
+	if filename == "" {
+		return nil, nil
+	}
+	s := NewFileSink(filename)
+	var err error
+	if v := os.Getenv("TRACE_FILE_MAX_SIZE"); v != "" {
+		if s.MaxSize, err = parseSize(v); err != nil {
+			return nil, fmt.Errorf("TRACE_FILE_MAX_SIZE: %v", err)
+		}
+	}
+	if v := os.Getenv("TRACE_FILE_ROTATE_EVERY"); v != "" {
+		if s.RotateEvery, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("TRACE_FILE_ROTATE_EVERY: %v", err)
+		}
+	}
+	if v := os.Getenv("TRACE_FILE_MAX_BACKUPS"); v != "" {
+		if s.MaxBackups, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("TRACE_FILE_MAX_BACKUPS: %v", err)
+		}
+	}
+	if v := os.Getenv("TRACE_FILE_COMPRESS"); v != "" {
+		if s.Compress, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("TRACE_FILE_COMPRESS: %v", err)
+		}
+	}
+	return s, nil
+}
+
+// parseSize parses a size in bytes with an optional K, M or G suffix,
+// optionally followed by B or iB, for multiples of 1024.
+func parseSize(s string) (int64, error) {
+	str := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(s), "B"), "I")
+	mult := int64(1)
+	if n := len(str); n > 0 {
+		switch str[n-1] {
+		case 'K':
+			mult = 1 << 10
+		case 'M':
+			mult = 1 << 20
+		case 'G':
+			mult = 1 << 30
+		}
+		if mult != 1 {
+			str = str[:n-1]
+		}
+	}
+	n, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
+
+// Write appends p to the file, rotating it first when needed.
+func (s *FileSink) Write(p []byte) (n int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		if err = s.open(); err != nil {
+			return 0, err
+		}
+	}
+	if s.needRotate(int64(len(p))) {
+		if err = s.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err = s.file.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+// Rotate renames the file to a backup, compressing and removing old
+// backups as configured, and opens a new file.
+func (s *FileSink) Rotate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rotate()
+}
+
+// Reopen closes and reopens the file without rotating it. It is used
+// after the file has been moved by an external tool such as logrotate.
+func (s *FileSink) Reopen() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.close(); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// ReopenOnSignal reopens the file whenever one of the passed signals is
+// received. If none are passed, SIGHUP is used on Unix systems, while
+// nothing is done on other systems. Errors reopening the file are
+// reported to os.Stderr. Call the returned function to stop.
+func (s *FileSink) ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = defaultReopenSignals
+	}
+	if len(sigs) == 0 {
+		return func() {}
+	}
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sigs...)
+	go func() {
+		for {
+			select {
+			case <-c:
+				if err := s.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "trace: reopen %s: %v\n", s.Filename, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+// Close closes the file and waits for rotated files being compressed.
+// A later write opens it again.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	err := s.close()
+	s.mu.Unlock()
+	s.compressing.Wait()
+	return err
+}
+
+// open opens the file for appending and records its size and the
+// current rotation interval.
+func (s *FileSink) open() error {
+	if dir := filepath.Dir(s.Filename); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(s.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.file = f
+	s.size = fi.Size()
+	s.interval = s.currentInterval()
+	return nil
+}
+
+// close closes the file if it is open.
+func (s *FileSink) close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// currentInterval returns the start of the current rotation interval.
+func (s *FileSink) currentInterval() time.Time {
+	if s.RotateEvery <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Truncate(s.RotateEvery)
+}
+
+// needRotate returns whether the file must be rotated before writing n
+// more bytes to it.
+func (s *FileSink) needRotate(n int64) bool {
+	if s.MaxSize > 0 && s.size > 0 && s.size+n > s.MaxSize {
+		return true
+	}
+	return s.RotateEvery > 0 && s.size > 0 && !s.currentInterval().Equal(s.interval)
+}
+
+// rotate renames the file to a backup and opens a new file.
+func (s *FileSink) rotate() error {
+	if err := s.close(); err != nil {
+		return err
+	}
+	backup := s.Filename + "." + time.Now().UTC().Format(backupTimeFormat)
+	if err := os.Rename(s.Filename, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if s.Compress {
+		s.compressing.Add(1)
+		go s.compressBackup(backup)
+	} else if err := s.removeOldBackups(); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// compressBackup compresses the rotated file backup and then removes
+// old backups. It runs in the background, one rotated file at a time,
+// and reports errors to os.Stderr since there is no caller to return
+// them to.
+func (s *FileSink) compressBackup(backup string) {
+	defer s.compressing.Done()
+	s.bgMu.Lock()
+	defer s.bgMu.Unlock()
+	if err := compressFile(backup); err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "trace: compress %s: %v\n", backup, err)
+	}
+	if err := s.removeOldBackups(); err != nil {
+		fmt.Fprintf(os.Stderr, "trace: remove old backups of %s: %v\n", s.Filename, err)
+	}
+}
+
+// compressFile replaces name with a gzip compressed copy with the
+// compressSuffix added to its name.
+func compressFile(name string) (err error) {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := name + compressSuffix + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = out.Close()
+			_ = os.Remove(tmp)
+		}
+	}()
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err != nil {
+		return err
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, name+compressSuffix); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
+
+// Backups returns the names of the rotated files, oldest first. A file
+// being compressed may be listed under its uncompressed name.
+func (s *FileSink) Backups() ([]string, error) {
+	dir, base := filepath.Split(s.Filename)
+	if dir == "" {
+		dir = "."
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := base + "."
+	var backups []string
+	for _, fi := range files {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), compressSuffix)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// removeOldBackups removes the oldest rotated files beyond MaxBackups.
+func (s *FileSink) removeOldBackups() error {
+	if s.MaxBackups <= 0 {
+		return nil
+	}
+	backups, err := s.Backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > s.MaxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
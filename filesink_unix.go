@@ -0,0 +1,17 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris || aix
+// +build linux darwin freebsd netbsd openbsd dragonfly solaris aix
+
+package trace
+
+import (
+	"os"
+	"syscall"
+)
+
+// defaultReopenSignals are the signals used by ReopenOnSignal when none
+// are passed.
+var defaultReopenSignals = []os.Signal{syscall.SIGHUP}
@@ -0,0 +1,73 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package trace_test
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+
+	"github.com/apatters/go-trace"
+	"github.com/apatters/go-trace/spew"
+	"github.com/stretchr/testify/assert"
+)
+
+type genericKey string
+
+type genericValue struct {
+	N int
+}
+
+type genericMap[K comparable, V any] struct {
+	Entries map[K]V
+}
+
+func TestDumpTypeNames(t *testing.T) {
+	var buf = make([]byte, 0, 256)
+	out := bytes.NewBuffer(buf)
+	savedWriter := trace.Writer
+	trace.Writer = out
+
+	m := &genericMap[genericKey, genericValue]{
+		Entries: map[genericKey]genericValue{"a": {1}},
+	}
+	cases := []struct {
+		mode    spew.TypeNameMode
+		ptrType string
+		mapType string
+	}{
+		{spew.TypeNameFull,
+			"*trace_test.genericMap[github.com/apatters/go-trace_test.genericKey,github.com/apatters/go-trace_test.genericValue]",
+			"map[trace_test.genericKey]trace_test.genericValue"},
+		{spew.TypeNameShort,
+			"*trace_test.genericMap[trace_test.genericKey,trace_test.genericValue]",
+			"map[trace_test.genericKey]trace_test.genericValue"},
+		{spew.TypeNameCompact,
+			"*trace_test.genericMap[...]",
+			"map[trace_test.genericKey]trace_test.genericValue"},
+		{spew.TypeNameBase,
+			"*genericMap",
+			"map[genericKey]genericValue"},
+	}
+	for _, c := range cases {
+		trace.SpewCS.TypeNames = c.mode
+		out.Reset()
+		trace.Dump(m)
+		t.Logf("out = %s", out)
+		assert.Regexp(t, `^### generics_test.go:[\d]+\n`+regexp.QuoteMeta("("+c.ptrType+")(")+
+			`0x[[:xdigit:]]+`+regexp.QuoteMeta(")({\n\tEntries: ("+c.mapType+") (len=1) {\n"), out.String())
+		assert.Regexp(t, `^`+regexp.QuoteMeta("("+c.ptrType+"){Entries:("+c.mapType+")"),
+			trace.SpewCS.Sprintf("%#v", m))
+	}
+
+	trace.SpewCS.TypeNames = spew.TypeNameFull
+	trace.SpewCS.TypeAliases = map[string]string{"trace_test.genericKey": "K"}
+	assert.Regexp(t, `^\(map\[K\]trace_test.genericValue\)`, trace.SpewCS.Sprintf("%#v", m.Entries))
+	trace.SpewCS.TypeAliases = nil
+	trace.Writer = savedWriter
+}
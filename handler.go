@@ -0,0 +1,157 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// controlState is the JSON representation of the trace settings shown
+// and changed by Handler.
+type controlState struct {
+	TraceLevel int            `json:"trace_level"`
+	Categories map[string]int `json:"categories"`
+	VModule    string         `json:"vmodule"`
+	Sinks      []controlSink  `json:"sinks"`
+}
+
+// controlSink is the JSON representation of a sink in controlState.
+type controlSink struct {
+	Index int    `json:"index"`
+	Type  string `json:"type"`
+	Level *int   `json:"level,omitempty"`
+}
+
+// controlChange is the JSON representation of a change to the trace
+// settings. Omitted settings are left unchanged.
+type controlChange struct {
+	TraceLevel *int            `json:"trace_level"`
+	Categories map[string]*int `json:"categories"`
+	VModule    *string         `json:"vmodule"`
+	Sinks      []struct {
+		Index int `json:"index"`
+		Level int `json:"level"`
+	} `json:"sinks"`
+}
+
+// Handler returns an http.Handler which shows the trace settings as
+// JSON in response to GET requests, and changes them in response to PUT
+// requests holding the settings to change, e.g.
+//
+//	{"trace_level": 2, "sinks": [{"index": 0, "level": 3}]}
+//
+// Sinks are identified by their index in Sinks. Only the levels of the
+// sinks of this package can be changed. The categories setting maps
+// category names to the levels set with SetCategoryLevel, and a change
+// naming a category with a null level resets it, e.g.
+//
+//	{"categories": {"net": 3, "db": null}, "vmodule": "server=2,conn*=3"}
+//
+// The vmodule setting holds the rules set with SetVModule, and a change
+// replaces them all. A change is rejected as a whole if any part of it
+// is invalid, and is otherwise applied at once, so tracing goroutines
+// never see part of it. Mount the handler on a debug mux, e.g.
+//
+//	http.Handle("/debug/trace", trace.Handler())
+func Handler() http.Handler {
+	return http.HandlerFunc(serveControl)
+}
+
+// serveControl handles the requests of Handler.
+func serveControl(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		if err := applyControl(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(currentControl())
+}
+
+// currentControl returns the current trace settings.
+func currentControl() controlState {
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+	state := controlState{
+		TraceLevel: loadLevel(&TraceLevel),
+		Categories: make(map[string]int, len(router.categories)),
+		VModule:    formatVModule(router.vmodule),
+		Sinks:      []controlSink{},
+	}
+	for name, level := range router.categories {
+		state.Categories[name] = level
+	}
+	for i, s := range router.sinks {
+		cs := controlSink{Index: i, Type: fmt.Sprintf("%T", s)}
+		if level, ok := sinkLevel(s); ok {
+			cs.Level = &level
+		}
+		state.Sinks = append(state.Sinks, cs)
+	}
+	return state
+}
+
+// applyControl applies the change in the body of req to the trace
+// settings, or returns an error without changing anything.
+func applyControl(req *http.Request) error {
+	var change controlChange
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&change); err != nil {
+		return fmt.Errorf("invalid settings: %v", err)
+	}
+	var rules []vmoduleRule
+	if change.VModule != nil {
+		var err error
+		if rules, err = parseVModule(*change.VModule); err != nil {
+			return err
+		}
+	}
+
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	levels := make([]*int, len(change.Sinks))
+	for i, sc := range change.Sinks {
+		if sc.Index < 0 || sc.Index >= len(router.sinks) {
+			return fmt.Errorf("no sink at index %d", sc.Index)
+		}
+		ls, ok := router.sinks[sc.Index].(levelSink)
+		if !ok {
+			return fmt.Errorf("the level of sink %d (%T) cannot be changed", sc.Index, router.sinks[sc.Index])
+		}
+		levels[i] = ls.levelField()
+	}
+	if change.TraceLevel != nil {
+		storeLevel(&TraceLevel, *change.TraceLevel)
+	}
+	for i, sc := range change.Sinks {
+		storeLevel(levels[i], sc.Level)
+	}
+	for name, level := range change.Categories {
+		if level == nil {
+			delete(router.categories, name)
+			continue
+		}
+		if router.categories == nil {
+			router.categories = make(map[string]int)
+		}
+		router.categories[name] = *level
+	}
+	if change.VModule != nil {
+		router.vmodule = rules
+	}
+	return nil
+}
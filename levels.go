@@ -0,0 +1,174 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package trace
+
+import (
+	"fmt"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// vmoduleRule sets the trace level of the source files whose base
+// names, with or without the .go suffix, match pattern.
+type vmoduleRule struct {
+	pattern string
+	level   int
+}
+
+// parseVModule parses a comma separated list of pattern=level rules.
+func parseVModule(spec string) ([]vmoduleRule, error) {
+	var rules []vmoduleRule
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		eq := strings.LastIndex(part, "=")
+		if eq <= 0 {
+			return nil, fmt.Errorf("trace: vmodule rule %q is not pattern=level", part)
+		}
+		pattern := part[:eq]
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("trace: vmodule pattern %q: %v", pattern, err)
+		}
+		level, err := strconv.Atoi(part[eq+1:])
+		if err != nil {
+			return nil, fmt.Errorf("trace: vmodule level in %q: %v", part, err)
+		}
+		rules = append(rules, vmoduleRule{pattern: pattern, level: level})
+	}
+	return rules, nil
+}
+
+// formatVModule returns rules in the form parsed by parseVModule.
+func formatVModule(rules []vmoduleRule) string {
+	parts := make([]string, len(rules))
+	for i, rule := range rules {
+		parts[i] = rule.pattern + "=" + strconv.Itoa(rule.level)
+	}
+	return strings.Join(parts, ",")
+}
+
+// SetVModule sets the trace levels of the PrintLevel, PrintlnLevel and
+// PrintfLevel records of individual source files, like glog's -vmodule
+// flag, from a comma separated list of pattern=level rules, e.g.
+// "server=2,conn*=3". Each pattern is matched with path.Match against
+// the base name of the source file with and without its .go suffix, and
+// the first matching rule applies. The records of a file a rule applies to
+// are output at or below its level regardless of TraceLevel, the levels
+// of the sinks and SetCategoryLevel, and are sent to every sink, whose
+// Filter still applies. An empty spec removes the rules. The rules are
+// unchanged if spec is invalid.
+//
+// While any rules or category levels are set, the level functions look
+// up their caller before deciding whether to output a record.
+func SetVModule(spec string) error {
+	rules, err := parseVModule(spec)
+	if err != nil {
+		return err
+	}
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	router.vmodule = rules
+	return nil
+}
+
+// VModule returns the rules set by SetVModule.
+func VModule() string {
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+	return formatVModule(router.vmodule)
+}
+
+// SetCategoryLevel sets the trace level of the records output by the
+// level methods of the categories named name (see Category). They are
+// output at or below level regardless of TraceLevel and the levels of
+// the sinks, and are sent to every sink, whose Filter still applies.
+func SetCategoryLevel(name string, level int) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	if router.categories == nil {
+		router.categories = make(map[string]int)
+	}
+	router.categories[name] = level
+}
+
+// ResetCategoryLevel removes the level set by SetCategoryLevel for the
+// categories named name, so their records follow TraceLevel and the
+// levels of the sinks again.
+func ResetCategoryLevel(name string) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	delete(router.categories, name)
+}
+
+// CategoryLevels returns the levels set by SetCategoryLevel by
+// category name.
+func CategoryLevels() map[string]int {
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+	levels := make(map[string]int, len(router.categories))
+	for name, level := range router.categories {
+		levels[name] = level
+	}
+	return levels
+}
+
+// ruleLevel returns the level set by SetVModule for file, or else by
+// SetCategoryLevel for category, if any. The caller must hold
+// router.mu.
+func ruleLevel(file, category string) (int, bool) {
+	name := strings.TrimSuffix(file, ".go")
+	for _, rule := range router.vmodule {
+		if ok, _ := path.Match(rule.pattern, name); ok {
+			return rule.level, true
+		}
+		if ok, _ := path.Match(rule.pattern, file); ok {
+			return rule.level, true
+		}
+	}
+	if category != "" {
+		level, ok := router.categories[category]
+		return level, ok
+	}
+	return 0, false
+}
+
+// levelRecord returns a record at level in category for the caller of
+// the level function calling it, or nil if the record would not be
+// output. The caller is only looked up while levels are set with
+// SetVModule or SetCategoryLevel, or if the record would be output
+// according to TraceLevel or the sinks.
+func levelRecord(level int, category string) *Record {
+	router.mu.RLock()
+	rules := len(router.vmodule) > 0 || len(router.categories) > 0
+	enabled := levelEnabledLocked(level)
+	router.mu.RUnlock()
+	if !rules && !enabled {
+		return nil
+	}
+
+	pc, filename, line, _ := runtime.Caller(2)
+	r := newRecord(level, pc, filename, line)
+	r.Category = category
+	if !rules {
+		return r
+	}
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+	if limit, ok := ruleLevel(r.File, category); ok {
+		if level > limit {
+			return nil
+		}
+		r.ruled = true
+		return r
+	}
+	if !levelEnabledLocked(level) {
+		return nil
+	}
+	return r
+}
@@ -0,0 +1,315 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package trace
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrNotConnected is returned by NetSink writes made while the
+// connection is down. The records are buffered and sent once it is
+// restored.
+var ErrNotConnected = errors.New("trace: not connected")
+
+// NetSink is a Sink which streams records at or below Level to a TCP,
+// UDP or Unix socket, one encoded record per write, for collecting
+// traces from another host or container. Each record is given the next
+// sequence number (see Record.Seq), starting at 1, so the receiver can
+// detect lost records.
+//
+// Records are buffered and sent by a background goroutine, so the
+// traced goroutines never wait for the network. The connection is
+// dialed for the first record. When dialing or writing fails, redialing
+// is retried with exponential backoff from MinBackoff to MaxBackoff
+// until the connection is restored, or immediately when the sink is
+// flushed. The oldest buffered records are dropped beyond MaxBuffer.
+// A record which fails to be written maxSendAttempts times, or which is
+// too large for the network, such as an oversized UDP datagram, is
+// dropped so it does not hold up the records behind it.
+type NetSink struct {
+	// Network and Addr are passed to net.Dial, e.g. "tcp" and
+	// "collector:4242", "udp" and "127.0.0.1:4242" or "unix" and
+	// "/run/trace.sock".
+	Network string
+	Addr    string
+
+	// Level is the highest trace level sent.
+	Level int
+
+	// Encoder encodes the records, TextEncoder{} if nil. Encoders
+	// which output a record per line, such as TextEncoder and
+	// JSONEncoder, make the stream easy to split.
+	Encoder Encoder
+
+	// MaxBuffer is the number of records buffered while the
+	// connection is down or slow.
+	MaxBuffer int
+
+	// MinBackoff and MaxBackoff bound the delay before redialing.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Timeout bounds the time taken to dial and to write each record,
+	// so a stalled receiver does not hold up the records behind it for
+	// long. Zero disables the timeout.
+	Timeout time.Duration
+
+	encMu    sync.Mutex // serializes numbering and encoding records
+	seq      uint64
+	mu       sync.Mutex
+	cond     *sync.Cond
+	conn     net.Conn
+	pending  []pendingRecord
+	dropped  uint64
+	backoff  time.Duration
+	retryAt  time.Time
+	running  bool // the background goroutine is sending
+	busy     bool // a record is being written to conn
+	closed   bool
+	failures uint64 // the number of failed dials and writes
+	err      error  // the last error dialing or writing
+	wake     chan struct{}
+}
+
+// maxSendAttempts is the number of times a NetSink tries to write a
+// record before dropping it.
+const maxSendAttempts = 3
+
+// pendingRecord is an encoded record buffered by a NetSink.
+type pendingRecord struct {
+	p        []byte
+	attempts int // the number of failed writes
+}
+
+// NewNetSink returns a NetSink sending records at or below level to
+// addr on network encoded with enc. It buffers DefaultQueueSize records
+// during outages, redials after 100ms to 30s and times out after 5s.
+func NewNetSink(network, addr string, level int, enc Encoder) *NetSink {
+	return &NetSink{
+		Network:    network,
+		Addr:       addr,
+		Level:      level,
+		Encoder:    enc,
+		MaxBuffer:  DefaultQueueSize,
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 30 * time.Second,
+		Timeout:    5 * time.Second,
+	}
+}
+
+// Enabled implements Sink.
+func (s *NetSink) Enabled(level int) bool {
+	return level <= loadLevel(&s.Level)
+}
+
+// WriteRecord implements Sink. It buffers the record for the background
+// goroutine and returns ErrNotConnected if the connection is down.
+func (s *NetSink) WriteRecord(r *Record) error {
+	enc := s.Encoder
+	if enc == nil {
+		enc = TextEncoder{}
+	}
+
+	// Records are encoded outside s.mu so the background goroutine is
+	// not held up, but in sequence number order.
+	s.encMu.Lock()
+	defer s.encMu.Unlock()
+	s.seq++
+	seqRecord := *r
+	seqRecord.Seq = s.seq
+	p, err := enc.Encode(&seqRecord)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, pendingRecord{p: p})
+	s.trim()
+	s.closed = false
+	s.start()
+	if s.conn == nil && s.err != nil {
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// trim drops the oldest buffered records beyond MaxBuffer.
+func (s *NetSink) trim() {
+	for len(s.pending) > 1 && len(s.pending) > s.MaxBuffer {
+		s.pending[0] = pendingRecord{}
+		s.pending = s.pending[1:]
+		s.dropped++
+	}
+}
+
+// start starts the background goroutine unless it is running. The
+// caller must hold s.mu.
+func (s *NetSink) start() {
+	if s.cond == nil {
+		s.cond = sync.NewCond(&s.mu)
+		s.wake = make(chan struct{}, 1)
+	}
+	if !s.running {
+		s.running = true
+		go s.run()
+	}
+}
+
+// run sends the buffered records, dialing as needed, until none are
+// left or the sink is closed while the connection is down.
+func (s *NetSink) run() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.pending) > 0 && !(s.closed && s.conn == nil) {
+		if s.conn == nil {
+			if delay := time.Until(s.retryAt); delay > 0 {
+				s.mu.Unlock()
+				s.sleep(delay)
+				s.mu.Lock()
+				continue
+			}
+			s.mu.Unlock()
+			conn, err := net.DialTimeout(s.Network, s.Addr, s.Timeout)
+			s.mu.Lock()
+			if err != nil {
+				s.fail(err)
+				continue
+			}
+			s.conn = conn
+			s.backoff = 0
+			s.err = nil
+		}
+
+		rec, conn := s.pending[0], s.conn
+		s.pending[0] = pendingRecord{}
+		s.pending = s.pending[1:]
+		s.busy = true
+		s.mu.Unlock()
+		if s.Timeout > 0 {
+			_ = conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+		}
+		_, err := conn.Write(rec.p)
+		s.mu.Lock()
+		s.busy = false
+		switch {
+		case err == nil:
+		case isMsgSizeError(err):
+			// The record can never be sent, but the connection
+			// is still good.
+			s.dropped++
+		default:
+			// Resend the record once the connection is restored,
+			// unless it has failed too often.
+			if rec.attempts++; rec.attempts < maxSendAttempts {
+				s.pending = append([]pendingRecord{rec}, s.pending...)
+				s.trim()
+			} else {
+				s.dropped++
+			}
+			_ = s.conn.Close()
+			s.conn = nil
+			s.fail(err)
+		}
+		s.cond.Broadcast()
+	}
+	s.running = false
+	s.cond.Broadcast()
+}
+
+// fail records err and doubles the delay before redialing, within
+// MinBackoff and MaxBackoff.
+func (s *NetSink) fail(err error) {
+	s.err = err
+	s.failures++
+	s.backoff *= 2
+	if s.backoff < s.MinBackoff {
+		s.backoff = s.MinBackoff
+	}
+	if s.MaxBackoff > 0 && s.backoff > s.MaxBackoff {
+		s.backoff = s.MaxBackoff
+	}
+	s.retryAt = time.Now().Add(s.backoff)
+	s.cond.Broadcast()
+}
+
+// sleep waits for delay, or until the sink is flushed or closed.
+func (s *NetSink) sleep(delay time.Duration) {
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-s.wake:
+	}
+}
+
+// redialNow makes the background goroutine redial at once. The caller
+// must hold s.mu.
+func (s *NetSink) redialNow() {
+	s.retryAt = time.Time{}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Flush waits until the buffered records have been sent, redialing
+// immediately if the connection is down. It returns the error if
+// dialing or writing fails meanwhile.
+func (s *NetSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.pending) == 0 && !s.busy {
+		return nil
+	}
+	s.closed = false
+	s.start()
+	s.redialNow()
+	failures := s.failures
+	for (len(s.pending) > 0 || s.busy) && s.running && s.failures == failures {
+		s.cond.Wait()
+	}
+	switch {
+	case s.failures != failures:
+		return s.err
+	case len(s.pending) > 0:
+		return ErrNotConnected
+	}
+	return nil
+}
+
+// Dropped returns the number of buffered records dropped beyond
+// MaxBuffer, or because they could not be sent.
+func (s *NetSink) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Close flushes the buffered records and closes the connection. Records
+// which could not be sent stay buffered, and a later record dials the
+// connection again.
+func (s *NetSink) Close() error {
+	err := s.Flush()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	if s.cond != nil {
+		s.redialNow()
+		for s.busy {
+			s.cond.Wait()
+		}
+	}
+	if s.conn != nil {
+		if cerr := s.conn.Close(); err == nil {
+			err = cerr
+		}
+		s.conn = nil
+	}
+	return err
+}
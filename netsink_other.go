@@ -0,0 +1,15 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !solaris && !aix
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!solaris,!aix
+
+package trace
+
+// isMsgSizeError returns whether err reports a record too large to be
+// sent in one write. It is not recognized on this system, so such
+// records are dropped once they have failed maxSendAttempts times.
+func isMsgSizeError(err error) bool {
+	return false
+}
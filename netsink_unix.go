@@ -0,0 +1,26 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris || aix
+// +build linux darwin freebsd netbsd openbsd dragonfly solaris aix
+
+package trace
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// isMsgSizeError returns whether err reports a record too large to be
+// sent in one write, such as an oversized UDP datagram.
+func isMsgSizeError(err error) bool {
+	if oe, ok := err.(*net.OpError); ok {
+		err = oe.Err
+	}
+	if se, ok := err.(*os.SyscallError); ok {
+		err = se.Err
+	}
+	return err == syscall.EMSGSIZE
+}
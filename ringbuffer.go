@@ -0,0 +1,168 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package trace
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// Flusher is implemented by sinks which hold trace output until they
+// are flushed, such as RingBuffer.
+type Flusher interface {
+	Flush() error
+}
+
+// RingBuffer is an io.Writer which keeps the most recent trace records
+// in memory instead of writing them anywhere, for flight recorder style
+// tracing. Each trace function call writes one record. The records are
+// written to Output when the buffer is flushed, by calling Flush,
+// FlushOnPanic or FlushOnSignal.
+type RingBuffer struct {
+	// Output receives the records when the buffer is flushed. If nil,
+	// the records are written to os.Stderr.
+	Output io.Writer
+
+	// MaxRecords is the number of records kept. Zero keeps any
+	// number of records within MaxBytes.
+	MaxRecords int
+
+	// MaxBytes is the total size in bytes of the records kept. Only
+	// the end of a single record larger than MaxBytes is kept. Zero
+	// keeps records of any size within MaxRecords.
+	MaxBytes int
+
+	mu      sync.Mutex
+	records [][]byte
+	size    int
+	dropped int
+}
+
+// NewRingBuffer returns a RingBuffer which keeps at most maxRecords
+// records and maxBytes bytes, and writes them to out when flushed. A
+// zero limit is not enforced.
+func NewRingBuffer(out io.Writer, maxRecords, maxBytes int) *RingBuffer {
+	return &RingBuffer{Output: out, MaxRecords: maxRecords, MaxBytes: maxBytes}
+}
+
+// Write stores a copy of p as a record, discarding the oldest records
+// as needed to stay within MaxRecords and MaxBytes.
+func (r *RingBuffer) Write(p []byte) (n int, err error) {
+	rec := p
+	if r.MaxBytes > 0 && len(rec) > r.MaxBytes {
+		rec = rec[len(rec)-r.MaxBytes:]
+	}
+	rec = append([]byte(nil), rec...)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, rec)
+	r.size += len(rec)
+	for len(r.records) > 0 &&
+		(r.MaxRecords > 0 && len(r.records) > r.MaxRecords ||
+			r.MaxBytes > 0 && r.size > r.MaxBytes) {
+		r.size -= len(r.records[0])
+		r.records[0] = nil
+		r.records = r.records[1:]
+		r.dropped++
+	}
+	return len(p), nil
+}
+
+// Len returns the number of records held.
+func (r *RingBuffer) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.records)
+}
+
+// Reset discards the records held.
+func (r *RingBuffer) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = nil
+	r.size = 0
+	r.dropped = 0
+}
+
+// Flush writes the records held to Output, oldest first, preceded by
+// a line noting how many older records were discarded, if any. The
+// buffer is empty afterwards.
+func (r *RingBuffer) Flush() error {
+	r.mu.Lock()
+	records, dropped := r.records, r.dropped
+	r.records = nil
+	r.size = 0
+	r.dropped = 0
+	r.mu.Unlock()
+
+	out := r.Output
+	if out == nil {
+		out = os.Stderr
+	}
+	if dropped > 0 {
+		if _, err := fmt.Fprintf(out, "%s%d earlier records discarded\n", Leader, dropped); err != nil {
+			return err
+		}
+	}
+	for _, rec := range records {
+		if _, err := fwrite(out, rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushWriter flushes Writer if it is a Flusher.
+func flushWriter() {
+	if f, ok := Writer.(Flusher); ok {
+		_ = f.Flush()
+	}
+}
+
+// FlushOnPanic flushes Writer and the sinks which are Flushers, such
+// as a RingBuffer, when the calling function panics, and then
+// continues panicking. It must be deferred directly:
+//
+//	defer trace.FlushOnPanic()
+func FlushOnPanic() {
+	if r := recover(); r != nil {
+		flushSinks()
+		panic(r)
+	}
+}
+
+// FlushOnSignal flushes Writer and the sinks which are Flushers, such
+// as a RingBuffer, whenever one of the passed signals is received, e.g.
+// syscall.SIGUSR1. Call the returned function to stop. Nothing is done
+// if no signals are passed.
+func FlushOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		return func() {}
+	}
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, sigs...)
+	go func() {
+		for {
+			select {
+			case <-c:
+				flushSinks()
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
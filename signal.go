@@ -0,0 +1,105 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package trace
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+)
+
+// handleSignals raises the trace levels by one when raise is received,
+// lowers them by one when lower is received, and dumps the goroutine
+// stacks and flushes the sinks when one of dump is received. Nil raise
+// and lower signals are not handled.
+func handleSignals(raise, lower os.Signal, dump []os.Signal) (stop func()) {
+	var sigs []os.Signal
+	for _, sig := range []os.Signal{raise, lower} {
+		if sig != nil {
+			sigs = append(sigs, sig)
+		}
+	}
+	sigs = append(sigs, dump...)
+	if len(sigs) == 0 {
+		return func() {}
+	}
+	c := make(chan os.Signal, len(sigs))
+	done := make(chan struct{})
+	signal.Notify(c, sigs...)
+	go func() {
+		for {
+			select {
+			case sig := <-c:
+				switch {
+				case raise != nil && sig == raise:
+					changeLevels(1)
+				case lower != nil && sig == lower:
+					changeLevels(-1)
+				default:
+					dumpStacks()
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
+
+// addLevel returns level changed by delta, kept within 0 and AllLevels.
+func addLevel(level, delta int) int {
+	level += delta
+	if level < 0 {
+		return 0
+	}
+	if level > AllLevels {
+		return AllLevels
+	}
+	return level
+}
+
+// changeLevels changes TraceLevel and the level of each sink with one by
+// delta, and traces the new TraceLevel.
+func changeLevels(delta int) {
+	router.mu.Lock()
+	level := addLevel(loadLevel(&TraceLevel), delta)
+	storeLevel(&TraceLevel, level)
+	for _, s := range router.sinks {
+		if ls, ok := s.(levelSink); ok {
+			storeLevel(ls.levelField(), addLevel(loadLevel(ls.levelField()), delta))
+		}
+	}
+	router.mu.Unlock()
+
+	pc, filename, line, _ := runtime.Caller(0)
+	printRecord(0, pc, filename, line, fmt.Sprintf("trace level %d", level))
+}
+
+// dumpStacks traces the stacks of all goroutines and then flushes the
+// sinks, so a RingBuffer outputs the records leading up to the dump.
+func dumpStacks() {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	pc, filename, line, _ := runtime.Caller(0)
+	r := newRecord(0, pc, filename, line)
+	r.Dump = string(buf)
+	emit(r)
+	flushSinks()
+}
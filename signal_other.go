@@ -0,0 +1,20 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly && !solaris && !aix
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly,!solaris,!aix
+
+package trace
+
+import (
+	"os"
+)
+
+// HandleSignals traces the stacks of all goroutines and flushes the
+// sinks when one of the passed dump signals is received. There is no
+// SIGUSR1 or SIGUSR2 on this system, so the trace levels cannot be
+// changed by signals. Call the returned function to stop.
+func HandleSignals(dump ...os.Signal) (stop func()) {
+	return handleSignals(nil, nil, dump)
+}
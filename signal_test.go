@@ -0,0 +1,81 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris || aix
+// +build linux darwin freebsd netbsd openbsd dragonfly solaris aix
+
+package trace_test
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/apatters/go-trace"
+	"github.com/stretchr/testify/assert"
+)
+
+// lockedBuffer is a bytes.Buffer which can be read while another
+// goroutine writes to it.
+type lockedBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
+// signalAndWait sends sig to the test process and waits until cond is
+// true.
+func signalAndWait(t *testing.T, sig syscall.Signal, cond func() bool) {
+	assert.NoError(t, syscall.Kill(os.Getpid(), sig))
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %v to be handled", sig)
+		}
+	}
+}
+
+func TestHandleSignals(t *testing.T) {
+	savedTraceLevel := trace.GetTraceLevel()
+	var out lockedBuffer
+	ring := trace.NewRingBuffer(&out, 0, 0)
+	sink := trace.NewWriterSink(ring, 1, nil)
+	trace.SetSinks(sink)
+	defer trace.SetSinks()
+	trace.SetTraceLevel(0)
+	stop := trace.HandleSignals(syscall.SIGHUP)
+	defer stop()
+
+	signalAndWait(t, syscall.SIGUSR1, func() bool { return trace.GetTraceLevel() == 1 })
+	signalAndWait(t, syscall.SIGUSR1, func() bool { return trace.GetTraceLevel() == 2 })
+	signalAndWait(t, syscall.SIGUSR2, func() bool { return trace.GetTraceLevel() == 1 })
+	trace.PrintLevel(2, "sink level 2")
+	trace.PrintLevel(3, "not traced")
+	signalAndWait(t, syscall.SIGHUP, func() bool {
+		return strings.Contains(out.String(), "goroutine ")
+	})
+	t.Logf("out = %s", out.String())
+	assert.Regexp(t, `^### signal.go:[\d]+ trace level 1\n`+
+		`### signal.go:[\d]+ trace level 2\n`+
+		`### signal.go:[\d]+ trace level 1\n`+
+		`### signal_test.go:[\d]+ sink level 2\n`+
+		`### signal.go:[\d]+\n`+regexp.QuoteMeta("goroutine "), out.String())
+	assert.Contains(t, out.String(), "TestHandleSignals")
+	trace.SetTraceLevel(savedTraceLevel)
+}
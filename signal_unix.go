@@ -0,0 +1,24 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris || aix
+// +build linux darwin freebsd netbsd openbsd dragonfly solaris aix
+
+package trace
+
+import (
+	"os"
+	"syscall"
+)
+
+// HandleSignals raises TraceLevel, and the level of each sink with one,
+// by one when SIGUSR1 is received and lowers them by one when SIGUSR2
+// is received, so tracing can be turned up on a running process, e.g.
+// with kill -USR1. When one of the passed dump signals is received, the
+// stacks of all goroutines are traced and the sinks are flushed, which
+// outputs the records held by a RingBuffer. Call the returned function
+// to stop.
+func HandleSignals(dump ...os.Signal) (stop func()) {
+	return handleSignals(syscall.SIGUSR1, syscall.SIGUSR2, dump)
+}
@@ -0,0 +1,385 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+package trace
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/apatters/go-trace/spew"
+)
+
+// AllLevels is a sink level which accepts records at every trace level.
+const AllLevels = math.MaxInt32
+
+// Record is the output of one trace function call.
+type Record struct {
+	Time  time.Time
+	Level int    // the level passed to Print*Level, otherwise 0
+	File  string // the base name of the source file
+	Line  int    // the source line number
+	Func  string // the package path qualified function name
+
+	// Category is the name of the Category which output the record,
+	// or empty for the functions of this package. The TextEncoder
+	// outputs it in brackets after the source line number.
+	Category string
+
+	// Seq is the sequence number assigned by sinks such as NetSink so
+	// receivers can detect lost records, or zero. The TextEncoder
+	// outputs it at the start of the leader line.
+	Seq uint64
+
+	// Message holds the text output by the Print*() functions and
+	// DumpLine after the leader. It is empty for the Dump functions.
+	Message string
+
+	// Dump holds the multi-line text output by the Dump functions
+	// after the leader line.
+	Dump string
+
+	// Values holds the values whose dump is in Dump or Message: the
+	// args of Dump and DumpLine, and the sub-value selected by
+	// DumpPath unless it is redacted. It is nil for DumpLocked, whose
+	// values may only be read while the lock is held, and for DumpGo
+	// and DumpDiff, whose output is not a dump of values.
+	Values []interface{}
+
+	// render returns Message or Dump as output with cs, which is used
+	// to color the output. It ignores cs when the values cannot be
+	// dumped again, e.g. because a lock was held while dumping, and is
+	// nil when the output is never colored.
+	render func(cs *spew.ConfigState) string
+
+	// ruled is set when a level set by SetVModule or SetCategoryLevel
+	// applies to the record, which is then sent to every sink.
+	ruled bool
+}
+
+// Sink is implemented by destinations of trace records. See AddSink.
+type Sink interface {
+	// Enabled reports whether records at level are wanted. Records
+	// are only formatted when at least one sink wants them.
+	Enabled(level int) bool
+
+	// WriteRecord outputs r. It is called concurrently by goroutines
+	// tracing at the same time.
+	WriteRecord(r *Record) error
+}
+
+// Encoder converts records into bytes for a WriterSink.
+type Encoder interface {
+	Encode(r *Record) ([]byte, error)
+}
+
+// TextEncoder encodes records in the default trace format: the
+// leader, source file name and line number followed by the message, or
+// by the dump on the following lines.
+type TextEncoder struct {
+	// Color enables coloring with ColorTheme.
+	Color bool
+}
+
+// Encode implements Encoder.
+func (e TextEncoder) Encode(r *Record) ([]byte, error) {
+	leader := formatLeader(r.File, r.Line, e.Color)
+	if r.Seq != 0 {
+		leader = strconv.FormatUint(r.Seq, 10) + " " + leader
+	}
+	if r.Category != "" {
+		leader += "[" + r.Category + "] "
+	}
+	body := r.Message
+	if r.Dump != "" {
+		body = r.Dump
+	}
+	if e.Color && r.render != nil {
+		body = r.render(colorConfig())
+	}
+	if r.Dump != "" {
+		return []byte(strings.TrimRight(leader, " \t\n") + "\n" + body), nil
+	}
+	return []byte(strings.TrimRight(leader+body, " \t\n") + "\n"), nil
+}
+
+// JSONEncoder encodes each record as a JSON object on a line of its
+// own, with the Values of the record encoded with the FormatJSON layout
+// of SpewCS, ignoring its MaxOutputBytes limit. Values which still do not
+// encode as valid JSON are output as JSON strings holding their dump.
+// The Dump of records without Values is output as the message.
+type JSONEncoder struct{}
+
+// jsonRecord is the JSON representation of a Record.
+type jsonRecord struct {
+	Seq      uint64            `json:"seq,omitempty"`
+	Time     string            `json:"time"`
+	Level    int               `json:"level"`
+	File     string            `json:"file"`
+	Line     int               `json:"line"`
+	Func     string            `json:"func,omitempty"`
+	Category string            `json:"category,omitempty"`
+	Message  string            `json:"msg,omitempty"`
+	Values   []json.RawMessage `json:"values,omitempty"`
+}
+
+// Encode implements Encoder.
+func (JSONEncoder) Encode(r *Record) ([]byte, error) {
+	jr := jsonRecord{
+		Seq:      r.Seq,
+		Time:     r.Time.Format(time.RFC3339Nano),
+		Level:    r.Level,
+		File:     r.File,
+		Line:     r.Line,
+		Func:     r.Func,
+		Category: r.Category,
+		Message:  r.Message,
+	}
+	if len(r.Values) > 0 {
+		cs := *SpewCS
+		cs.Format = spew.FormatJSON
+		cs.Compact = true
+		cs.Theme = nil
+		cs.MaxOutputBytes = 0
+		for _, v := range r.Values {
+			p := []byte(strings.TrimRight(cs.Sdump(v), "\n"))
+			if !json.Valid(p) {
+				var err error
+				if p, err = json.Marshal(string(p)); err != nil {
+					return nil, err
+				}
+			}
+			jr.Values = append(jr.Values, json.RawMessage(p))
+		}
+	} else if r.Dump != "" {
+		jr.Message = strings.TrimRight(r.Dump, "\n")
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(jr); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriterSink is a Sink which encodes records at or below Level, and
+// accepted by Filter, and writes them to W.
+type WriterSink struct {
+	W       io.Writer
+	Level   int
+	Encoder Encoder              // TextEncoder{} if nil
+	Filter  func(r *Record) bool // all records if nil
+
+	mu sync.Mutex
+}
+
+// NewWriterSink returns a WriterSink writing records at or below level
+// to w encoded with enc.
+func NewWriterSink(w io.Writer, level int, enc Encoder) *WriterSink {
+	return &WriterSink{W: w, Level: level, Encoder: enc}
+}
+
+// Enabled implements Sink.
+func (s *WriterSink) Enabled(level int) bool {
+	return level <= loadLevel(&s.Level)
+}
+
+// WriteRecord implements Sink.
+func (s *WriterSink) WriteRecord(r *Record) error {
+	if s.Filter != nil && !s.Filter(r) {
+		return nil
+	}
+	enc := s.Encoder
+	if enc == nil {
+		enc = TextEncoder{}
+	}
+	p, err := enc.Encode(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = fwrite(s.W, p)
+	return err
+}
+
+// Flush flushes W if it is a Flusher, such as a RingBuffer.
+func (s *WriterSink) Flush() error {
+	if f, ok := s.W.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// router fans trace records out to the sinks added with AddSink, and
+// holds the levels set by SetVModule and SetCategoryLevel.
+var router struct {
+	mu         sync.RWMutex
+	sinks      []Sink
+	vmodule    []vmoduleRule
+	categories map[string]int
+}
+
+// AddSink adds s to the destinations of trace records. Once a sink has
+// been added, records go to the sinks instead of Writer, and each sink
+// decides which levels it wants regardless of TraceLevel.
+func AddSink(s Sink) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	router.sinks = append(router.sinks, s)
+}
+
+// RemoveSink removes s from the destinations of trace records. Records
+// go to Writer again once the last sink is removed.
+func RemoveSink(s Sink) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	for i, sink := range router.sinks {
+		if sink == s {
+			router.sinks = append(router.sinks[:i:i], router.sinks[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetSinks replaces the destinations of trace records with sinks. With
+// no sinks, records go to Writer.
+func SetSinks(sinks ...Sink) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	router.sinks = append([]Sink(nil), sinks...)
+}
+
+// Sinks returns the sinks added with AddSink or SetSinks.
+func Sinks() []Sink {
+	router.mu.RLock()
+	defer router.mu.RUnlock()
+	return append([]Sink(nil), router.sinks...)
+}
+
+// levelSink is implemented by the sinks whose Level field can be
+// changed by SetSinkLevel.
+type levelSink interface {
+	levelField() *int
+}
+
+func (s *WriterSink) levelField() *int { return &s.Level }
+func (s *NetSink) levelField() *int    { return &s.Level }
+
+// loadLevel atomically loads the level at p, which is TraceLevel or the
+// Level field of a sink, so it can be read while it is being changed.
+func loadLevel(p *int) int {
+	if unsafe.Sizeof(*p) == 8 {
+		return int(atomic.LoadInt64((*int64)(unsafe.Pointer(p))))
+	}
+	return int(atomic.LoadInt32((*int32)(unsafe.Pointer(p))))
+}
+
+// storeLevel atomically stores level at p. Changes to several levels
+// are made while holding router.mu, so they are seen together by
+// levelEnabled and emit.
+func storeLevel(p *int, level int) {
+	if unsafe.Sizeof(*p) == 8 {
+		atomic.StoreInt64((*int64)(unsafe.Pointer(p)), int64(level))
+		return
+	}
+	atomic.StoreInt32((*int32)(unsafe.Pointer(p)), int32(level))
+}
+
+// SetTraceLevel sets TraceLevel safely while other goroutines are
+// tracing.
+func SetTraceLevel(level int) {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	storeLevel(&TraceLevel, level)
+}
+
+// GetTraceLevel returns TraceLevel as set by SetTraceLevel.
+func GetTraceLevel() int {
+	return loadLevel(&TraceLevel)
+}
+
+// SetSinkLevel sets the Level of s safely while other goroutines are
+// tracing. It returns false if s is not one of the sinks of this
+// package which have a Level.
+func SetSinkLevel(s Sink, level int) bool {
+	ls, ok := s.(levelSink)
+	if !ok {
+		return false
+	}
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	storeLevel(ls.levelField(), level)
+	return true
+}
+
+// sinkLevel returns the Level of s, if it has one.
+func sinkLevel(s Sink) (int, bool) {
+	if ls, ok := s.(levelSink); ok {
+		return loadLevel(ls.levelField()), true
+	}
+	return 0, false
+}
+
+// levelEnabledLocked reports whether a record at level would be
+// output, either to Writer according to TraceLevel or to any sink. The
+// caller must hold router.mu.
+func levelEnabledLocked(level int) bool {
+	if len(router.sinks) == 0 {
+		return level <= loadLevel(&TraceLevel)
+	}
+	for _, s := range router.sinks {
+		if s.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+// emit sends r to the sinks which want it, or to every sink if a level
+// set by SetVModule or SetCategoryLevel let it through, or to Writer
+// when there are no sinks. The sinks are chosen while holding router.mu and written to
+// after releasing it, so slow sinks do not hold up changes to the sinks
+// or their levels. Write errors are ignored, as they always have been
+// for Writer.
+func emit(r *Record) {
+	var buf [4]Sink
+	sinks := buf[:0]
+	router.mu.RLock()
+	for _, s := range router.sinks {
+		if r.ruled || s.Enabled(r.Level) {
+			sinks = append(sinks, s)
+		}
+	}
+	noSinks := len(router.sinks) == 0
+	router.mu.RUnlock()
+
+	if noSinks {
+		p, _ := TextEncoder{Color: useColor(Writer)}.Encode(r)
+		_, _ = fwrite(Writer, p)
+		return
+	}
+	for _, s := range sinks {
+		_ = s.WriteRecord(r)
+	}
+}
+
+// flushSinks flushes Writer and each sink which is a Flusher.
+func flushSinks() {
+	flushWriter()
+	for _, s := range Sinks() {
+		if f, ok := s.(Flusher); ok {
+			_ = f.Flush()
+		}
+	}
+}
@@ -24,6 +24,7 @@ package spew
 
 import (
 	"reflect"
+	"sync/atomic"
 	"unsafe"
 )
 
@@ -143,3 +144,61 @@ func init() {
 	}
 	panic("reflect.Value read-only flag has changed semantics")
 }
+
+// loadInt returns the value of the passed integer, loaded atomically when it
+// is addressable so values being changed by other goroutines, such as the
+// state of a sync.Mutex, are read safely.  Unsigned values are returned with
+// the same bits.
+func loadInt(v reflect.Value) (int64, bool) {
+	if !v.CanAddr() {
+		return plainInt(v)
+	}
+	p := unsafe.Pointer(v.UnsafeAddr())
+	switch v.Kind() {
+	case reflect.Int32:
+		return int64(atomic.LoadInt32((*int32)(p))), true
+	case reflect.Uint32:
+		return int64(atomic.LoadUint32((*uint32)(p))), true
+	case reflect.Int64, reflect.Uint64, reflect.Int, reflect.Uint, reflect.Uintptr:
+		if v.Type().Size() == 8 && uintptr(p)%8 == 0 {
+			return int64(atomic.LoadUint64((*uint64)(p))), true
+		}
+		if v.Type().Size() == 4 && v.Kind() == reflect.Int {
+			return int64(atomic.LoadInt32((*int32)(p))), true
+		}
+		if v.Type().Size() == 4 {
+			return int64(atomic.LoadUint32((*uint32)(p))), true
+		}
+	}
+	return plainInt(v)
+}
+
+// loadPointer returns the address held by the passed unsafe.Pointer, loaded
+// atomically when it is addressable.
+func loadPointer(v reflect.Value) uintptr {
+	return uintptr(loadUnsafePointer(v))
+}
+
+// loadUnsafePointer is loadPointer returning an unsafe.Pointer.
+func loadUnsafePointer(v reflect.Value) unsafe.Pointer {
+	if !v.CanAddr() {
+		return unsafe.Pointer(v.Pointer())
+	}
+	return atomic.LoadPointer((*unsafe.Pointer)(unsafe.Pointer(v.UnsafeAddr())))
+}
+
+// loadAtomicValue returns the value stored in the passed atomic.Value, loaded
+// with its Load method when it is addressable.
+func loadAtomicValue(v reflect.Value) interface{} {
+	if v.CanAddr() {
+		return unsafeReflectValue(v.Addr()).Interface().(*atomic.Value).Load()
+	}
+	return unsafeReflectValue(v.Field(0)).Interface()
+}
+
+// pointerAt returns a pointer of type reflect.PtrTo(t) holding the address
+// stored in v, which must be an unsafe.Pointer.  The address is loaded
+// atomically when v is addressable.
+func pointerAt(t reflect.Type, v reflect.Value) (reflect.Value, bool) {
+	return reflect.NewAt(t, loadUnsafePointer(v)), true
+}
@@ -20,7 +20,10 @@
 
 package spew
 
-import "reflect"
+import (
+	"reflect"
+	"sync/atomic"
+)
 
 const (
 	// UnsafeDisabled is a build-time constant which specifies whether or
@@ -36,3 +39,36 @@ const (
 func unsafeReflectValue(v reflect.Value) reflect.Value {
 	return v
 }
+
+// pointerAt returns a pointer of type reflect.PtrTo(t) holding the address
+// stored in v.  This is a stub version which always fails since converting
+// the address relies on access to the unsafe package.
+func pointerAt(t reflect.Type, v reflect.Value) (reflect.Value, bool) {
+	return reflect.Value{}, false
+}
+
+// loadInt returns the value of the passed integer.  This is a stub version
+// which reads the value without atomic operations since taking its address
+// relies on access to the unsafe package.  Unsigned values are returned with
+// the same bits.
+func loadInt(v reflect.Value) (int64, bool) {
+	return plainInt(v)
+}
+
+// loadPointer returns the address held by the passed unsafe.Pointer.  This is
+// a stub version which reads the address without atomic operations.
+func loadPointer(v reflect.Value) uintptr {
+	return v.Pointer()
+}
+
+// loadAtomicValue returns the value stored in the passed atomic.Value, loaded
+// with its Load method when that is permitted.
+func loadAtomicValue(v reflect.Value) interface{} {
+	if v.CanAddr() && v.Addr().CanInterface() {
+		return v.Addr().Interface().(*atomic.Value).Load()
+	}
+	if f := v.Field(0); f.CanInterface() {
+		return f.Interface()
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ByteFormat selects how Dump displays byte (uint8 under reflection) arrays
+// and slices.
+type ByteFormat int
+
+const (
+	// ByteHexdump displays bytes like the hexdump -C command, with
+	// offsets, byte values in hex, and ASCII output.  It is the default.
+	ByteHexdump ByteFormat = iota
+
+	// ByteHex displays bytes as a plain hex string such as 000aff.
+	ByteHex
+
+	// ByteBase64 displays bytes in standard base64 encoding.
+	ByteBase64
+
+	// ByteGoLiteral displays bytes as a Go composite literal such as
+	// []byte{0x00, 0x0a, 0xff}.
+	ByteGoLiteral
+
+	// ByteString displays bytes as a quoted string when they are valid
+	// UTF-8, and falls back to ByteHexdump otherwise.
+	ByteString
+)
+
+// byteFormat returns the format used for byte arrays or slices depending on
+// the passed kind.
+func (c *ConfigState) byteFormat(kind reflect.Kind) ByteFormat {
+	if kind == reflect.Array {
+		return c.ByteArrayFormat
+	}
+	return c.ByteFormat
+}
+
+// byteWidth returns the number of bytes shown per line of a hexdump or Go
+// literal.
+func (c *ConfigState) byteWidth() int {
+	if c.ByteWidth <= 0 {
+		return 16
+	}
+	return c.ByteWidth
+}
+
+// hexdump returns the hexdump of buf, formatted like hex.Dump but with the
+// passed number of bytes per line.
+func hexdump(buf []byte, width int) string {
+	if width == 16 {
+		return hex.Dump(buf)
+	}
+	var b strings.Builder
+	for off := 0; off < len(buf); off += width {
+		end := off + width
+		if end > len(buf) {
+			end = len(buf)
+		}
+		line := buf[off:end]
+		fmt.Fprintf(&b, "%08x  ", off)
+		for i := 0; i < width; i++ {
+			if i > 0 && i%8 == 0 {
+				b.WriteByte(' ')
+			}
+			if i < len(line) {
+				fmt.Fprintf(&b, "%02x ", line[i])
+			} else {
+				b.WriteString("   ")
+			}
+		}
+		b.WriteString(" |")
+		for _, c := range line {
+			if c < 32 || c > 126 {
+				c = '.'
+			}
+			b.WriteByte(c)
+		}
+		b.WriteString("|\n")
+	}
+	return b.String()
+}
+
+// dumpBytes displays the bytes of the byte array or slice v inline according
+// to the ByteFormat or ByteArrayFormat option.  It returns false, having
+// written nothing, when the bytes should be hexdumped instead.
+func (d *dumpState) dumpBytes(v reflect.Value) bool {
+	format := d.cs.byteFormat(v.Kind())
+	if format == ByteHexdump {
+		return false
+	}
+	buf, ok := byteContents(v)
+	if !ok || format == ByteString && !utf8.Valid(buf) {
+		return false
+	}
+	numBytes := d.cs.limitBytes(len(buf))
+	shown := buf[:numBytes]
+
+	switch format {
+	case ByteHex:
+		d.Write([]byte(hex.EncodeToString(shown)))
+
+	case ByteBase64:
+		d.Write([]byte(base64.StdEncoding.EncodeToString(shown)))
+
+	case ByteString:
+		d.setColor(d.cs.theme().String)
+		d.Write([]byte(strconv.Quote(string(shown))))
+		d.resetColor(d.cs.theme().String)
+
+	case ByteGoLiteral:
+		if v.Kind() == reflect.Array {
+			fmt.Fprintf(d, "[%d]byte{", v.Len())
+		} else {
+			d.Write([]byte("[]byte{"))
+		}
+		width := d.cs.byteWidth()
+		multiline := !d.cs.Compact && len(shown) > width
+		if multiline {
+			d.Write(newlineBytes)
+			d.depth++
+		}
+		for i, b := range shown {
+			switch {
+			case multiline && i%width == 0:
+				d.indent()
+			case i > 0:
+				d.Write(spaceBytes)
+			}
+			fmt.Fprintf(d, "0x%02x", b)
+			if multiline && (i%width == width-1 || i == len(shown)-1) {
+				d.Write(commaNewlineBytes)
+			} else if i < len(shown)-1 {
+				d.Write(commaBytes)
+			}
+		}
+		if multiline {
+			d.depth--
+			d.indent()
+		}
+		d.Write(closeBraceBytes)
+
+	default:
+		return false
+	}
+
+	// The marker for the bytes left out follows quoted strings directly, as
+	// it does for string values, and is separated from the other formats by a
+	// space as in the compact hexdump.
+	if numBytes < len(buf) {
+		if format != ByteString {
+			d.Write(spaceBytes)
+		}
+		printMore(d.w, len(buf)-numBytes, true)
+	}
+	return true
+}
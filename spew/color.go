@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+// Theme holds the ANSI escape sequences used to color the parts of the
+// output of Dump.  An empty sequence leaves that part uncolored.
+type Theme struct {
+	Type    string // type names, including the surrounding parentheses
+	String  string // string values
+	Number  string // integer, floating point, and complex values
+	Nil     string // the <nil> marker
+	Pointer string // pointer addresses
+	Cycle   string // the marker for already shown circular references
+}
+
+// DefaultTheme is a color theme suitable for terminals with either a light or
+// a dark background.
+var DefaultTheme = Theme{
+	Type:    "\x1b[36m",
+	String:  "\x1b[32m",
+	Number:  "\x1b[33m",
+	Nil:     "\x1b[35m",
+	Pointer: "\x1b[34m",
+	Cycle:   "\x1b[31m",
+}
+
+// ColorReset is the ANSI escape sequence which ends a colored section.
+const ColorReset = "\x1b[0m"
+
+// noTheme is the theme used when color is disabled.
+var noTheme Theme
+
+// theme returns the color theme to use, which has no colors when the Theme
+// option is not set.
+func (c *ConfigState) theme() *Theme {
+	if c.Theme == nil {
+		return &noTheme
+	}
+	return c.Theme
+}
+
+// setColor starts a section colored with the passed escape sequence.
+func (d *dumpState) setColor(color string) {
+	if color != "" {
+		d.Write([]byte(color))
+	}
+}
+
+// resetColor ends a section started by setColor with the same escape
+// sequence.
+func (d *dumpState) resetColor(color string) {
+	if color != "" {
+		d.Write([]byte(ColorReset))
+	}
+}
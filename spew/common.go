@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"reflect"
+	"runtime"
 	"sort"
 	"strconv"
 )
@@ -63,6 +65,11 @@ var (
 	closeMapBytes         = []byte("]")
 	lenEqualsBytes        = []byte("len=")
 	capEqualsBytes        = []byte("cap=")
+	nilBytes              = []byte("nil")
+	commaBytes            = []byte(",")
+	commaSpaceBytes       = []byte(", ")
+	ampersandBytes        = []byte("&")
+	elidedBytes           = []byte("<elided>")
 )
 
 // hexDigits is used to map a decimal value to a hex digit.
@@ -154,6 +161,156 @@ func handleMethods(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool)
 	return false
 }
 
+// TraceDumper is the interface implemented by types which control how they are
+// displayed by Dump and the custom formatter.  TraceDump writes the
+// representation of the value to w.  Unlike the error and Stringer
+// interfaces, TraceDump is invoked even when DisableMethods is set since
+// implementing it is an explicit request to be displayed that way.
+type TraceDumper interface {
+	TraceDump(w io.Writer)
+}
+
+// traceDumperType is the reflect.Type of the TraceDumper interface.
+var traceDumperType = reflect.TypeOf((*TraceDumper)(nil)).Elem()
+
+// errorType and stringerType are the reflect.Types of the interfaces whose
+// methods are called unless DisableMethods is set.
+var (
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// Snapshotter is the interface implemented by types which can return a
+// consistent copy of themselves, for instance by copying their fields while
+// holding the lock guarding them.  Dump and the custom formatter display the
+// value returned by Snapshot in place of the value itself, so types shared
+// between goroutines can be displayed without racing with their writers.
+// Like TraceDump, Snapshot is invoked even when DisableMethods is set.
+type Snapshotter interface {
+	Snapshot() interface{}
+}
+
+// snapshotterType is the reflect.Type of the Snapshotter interface.
+var snapshotterType = reflect.TypeOf((*Snapshotter)(nil)).Elem()
+
+// stdoutWriter wraps an io.Writer so writes made by custom formatters go
+// through write and work with go test.
+type stdoutWriter struct {
+	w io.Writer
+}
+
+// Write satisfies the io.Writer interface.
+func (s stdoutWriter) Write(p []byte) (n int, err error) {
+	return write(s.w, p)
+}
+
+// RegisterFormatter registers fn to display values of type t in place of
+// the generic reflection based display in Dump and the custom formatter.
+// Passing a nil fn removes any formatter registered for t.  Formatters are
+// looked up by exact type, so a formatter registered for T is used for the
+// value a *T points to, but not for a type whose underlying type is T.
+//
+// RegisterFormatter must not be called concurrently with any output using c.
+func (c *ConfigState) RegisterFormatter(t reflect.Type, fn func(w io.Writer, v reflect.Value)) {
+	if fn == nil {
+		delete(c.formatters, t)
+		if len(c.formatters) == 0 {
+			c.formatters = nil
+		}
+		return
+	}
+	if c.formatters == nil {
+		c.formatters = make(map[reflect.Type]func(io.Writer, reflect.Value))
+	}
+	c.formatters[t] = fn
+}
+
+// customFormatter returns a function which displays v using a formatter
+// registered with RegisterFormatter, a built-in renderer enabled by the
+// Renderers option, or the TraceDump method of v, in that order of
+// preference, or nil if none is available.  Like
+// handleMethods, it uses unsafe where available to reach unexported values
+// and pointer receivers.
+func (c *ConfigState) customFormatter(v reflect.Value) func(w io.Writer) {
+	// Formatters and TraceDump methods may need an interface for the
+	// value, so bypass the visibility rules for unexported struct fields
+	// the same way handleMethods does.
+	if !v.CanInterface() && !UnsafeDisabled {
+		v = unsafeReflectValue(v)
+	}
+
+	if fn, ok := c.formatters[v.Type()]; ok {
+		return func(w io.Writer) {
+			defer catchPanic(w, v)
+			fn(stdoutWriter{w}, v)
+		}
+	}
+
+	if !v.CanInterface() {
+		return nil
+	}
+	if render := c.builtinRenderer(v); render != nil {
+		return func(w io.Writer) {
+			defer catchPanic(w, v)
+			render(c, w, v)
+		}
+	}
+
+	v, ok := c.methodReceiver(v, traceDumperType)
+	if !ok {
+		return nil
+	}
+	return func(w io.Writer) {
+		defer catchPanic(w, v)
+		v.Interface().(TraceDumper).TraceDump(stdoutWriter{w})
+	}
+}
+
+// methodReceiver returns the value, or the address of the value, on which the
+// methods of interface type iface can be called for v.  Pointers and
+// interfaces are not considered since they are followed, and the methods
+// looked up on the value they point to, so the address is still displayed.
+func (c *ConfigState) methodReceiver(v reflect.Value, iface reflect.Type) (reflect.Value, bool) {
+	if kind := v.Kind(); kind == reflect.Ptr || kind == reflect.Interface {
+		return v, false
+	}
+	if v.Type().Implements(iface) {
+		return v, v.CanInterface()
+	}
+	if !reflect.PtrTo(v.Type()).Implements(iface) {
+		return v, false
+	}
+	if !c.DisablePointerMethods && !UnsafeDisabled && !v.CanAddr() {
+		v = unsafeReflectValue(v)
+	}
+	if !v.CanAddr() {
+		return v, false
+	}
+	return v.Addr(), true
+}
+
+// snapshot returns a consistent copy of v obtained from its Snapshot method
+// when it implements Snapshotter and a snapshot of its type is not already
+// being displayed.  The type is recorded in active until the returned
+// function is called, which keeps a Snapshot method returning its own type
+// from being called again on the copy.  ok is false when v is not replaced.
+func (c *ConfigState) snapshot(v reflect.Value, active map[reflect.Type]bool) (snap reflect.Value, done func(), ok bool) {
+	t := v.Type()
+	if active[t] {
+		return v, nil, false
+	}
+	if !v.CanInterface() && !UnsafeDisabled {
+		v = unsafeReflectValue(v)
+	}
+	rv, ok := c.methodReceiver(v, snapshotterType)
+	if !ok {
+		return v, nil, false
+	}
+	active[t] = true
+	snap = reflect.ValueOf(rv.Interface().(Snapshotter).Snapshot())
+	return snap, func() { delete(active, t) }, true
+}
+
 // printBool outputs a boolean value as true or false to Writer w.
 func printBool(w io.Writer, val bool) {
 	if val {
@@ -173,6 +330,22 @@ func printUint(w io.Writer, val uint64, base int) {
 	write(w, []byte(strconv.FormatUint(val, base)))
 }
 
+// printHexInt outputs a signed integer value in hexadecimal with a leading
+// '0x' prefix to Writer w.
+func printHexInt(w io.Writer, val int64) {
+	if val < 0 {
+		write(w, []byte("-0x"+strconv.FormatUint(uint64(-val), 16)))
+		return
+	}
+	write(w, []byte("0x"+strconv.FormatUint(uint64(val), 16)))
+}
+
+// printHexUint outputs an unsigned integer value in hexadecimal with a
+// leading '0x' prefix to Writer w.
+func printHexUint(w io.Writer, val uint64) {
+	write(w, []byte("0x"+strconv.FormatUint(val, 16)))
+}
+
 // printFloat outputs a floating point value using the specified precision,
 // which is expected to be 32 or 64bit, to Writer w.
 func printFloat(w io.Writer, val float64, precision int) {
@@ -194,6 +367,26 @@ func printComplex(w io.Writer, c complex128, floatPrecision int) {
 	write(w, closeParenBytes)
 }
 
+// printFunc outputs the name of a function and the file and line where it is
+// defined to Writer w.  The address is output instead when the function
+// cannot be resolved.
+func printFunc(w io.Writer, v reflect.Value) {
+	pc := v.Pointer()
+	fn := runtime.FuncForPC(pc)
+	if pc == 0 || fn == nil {
+		printHexPtr(w, pc)
+		return
+	}
+	file, line := fn.FileLine(fn.Entry())
+	write(w, []byte(fn.Name()))
+	write(w, spaceBytes)
+	write(w, openParenBytes)
+	write(w, []byte(path.Base(file)))
+	write(w, colonBytes)
+	write(w, []byte(strconv.Itoa(line)))
+	write(w, closeParenBytes)
+}
+
 // printHexPtr outputs a uintptr formatted as hexadecimal with a leading '0x'
 // prefix to Writer w.
 func printHexPtr(w io.Writer, p uintptr) {
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"reflect"
 )
 
 // ConfigState houses the configuration options used by spew to format and
@@ -49,6 +50,28 @@ type ConfigState struct {
 	// nested data structures.
 	MaxDepth int
 
+	// MaxStringLen limits the number of bytes of each string which are
+	// displayed.  The rest of a longer string is replaced by a marker
+	// such as "... 95 more bytes".  The default, 0, means there is no limit.
+	MaxStringLen int
+
+	// MaxElements limits the number of elements of each slice, array, and
+	// map which are displayed.  The remaining elements are replaced by a
+	// marker such as "... 95 more".  The default, 0, means there is no
+	// limit.
+	MaxElements int
+
+	// MaxHexdumpBytes limits the number of bytes of each byte slice or
+	// array which are displayed, whichever ByteFormat is used.  The default,
+	// 0, means there is no limit.
+	MaxHexdumpBytes int
+
+	// MaxOutputBytes limits the total number of bytes written by a single
+	// Dump call, or by the custom formatter for a single argument.  Output
+	// beyond the limit is replaced by "... <output truncated>", and the rest
+	// of the value is not visited.  The default, 0, means there is no limit.
+	MaxOutputBytes int
+
 	// DisableMethods specifies whether or not error and Stringer interfaces are
 	// invoked for types that implement them.
 	DisableMethods bool
@@ -98,6 +121,109 @@ type ConfigState struct {
 	// be spewed to strings and sorted by those strings.  This is only
 	// considered if SortKeys is true.
 	SpewKeys bool
+
+	// IncludeFields limits the struct fields which are displayed to those
+	// whose field path matches one of the patterns, along with the structs
+	// leading to them and everything beneath them.  A field path is the
+	// dot-separated list of struct field names leading to the field, such
+	// as "Header.ContentType".  Slice, array, and map elements and pointer
+	// indirections do not add to the path.  Each dot-separated element of
+	// a pattern is matched against one field name using path.Match syntax,
+	// so "Header.*" matches every field of Header, while "**" matches any
+	// number of field names, so "Header.**.password" matches a password
+	// field at any depth beneath Header.  A leading "*" also matches any
+	// number of field names, so "*.password" matches a password field at
+	// any depth.  The default, nil, displays all fields.
+	IncludeFields []string
+
+	// ExcludeFields specifies patterns, in the same form as IncludeFields,
+	// for struct fields which are not displayed.  Exclusions take priority
+	// over inclusions.
+	ExcludeFields []string
+
+	// RedactFields specifies case-insensitive patterns for struct field
+	// names and string map keys whose values are displayed as
+	// <redacted len=N> instead of their contents.  Names and patterns are
+	// split into words at underscores, hyphens and changes of case, and a
+	// pattern matches when its words match consecutive words of the name
+	// using path.Match syntax, so "token" matches AuthToken and
+	// X-Auth-Token but not MaxTokens.  Fields
+	// tagged with `trace:"redact"` are always redacted.  See
+	// DefaultRedactFields for a set of patterns covering common
+	// credentials.  The default, nil, redacts only tagged fields.
+	RedactFields []string
+
+	// Redactor, when set, is consulted for every struct field and string
+	// keyed map entry to decide whether its value should be redacted in
+	// addition to the RedactFields patterns.
+	Redactor Redactor
+
+	// Renderers selects the built-in renderers used to display common
+	// standard library types, such as time.Time and net.IP, in a readable
+	// form instead of their internal fields.  See Renderer for the
+	// available renderers.  No renderers are enabled by default.
+	Renderers Renderer
+
+	// Compact specifies that Dump should display each value on a single
+	// line while still showing types, lengths, capacities, and pointer
+	// chains.  Elements are separated by a comma and a space, byte slices
+	// are shown in hex rather than hexdumped, and the empty interface is
+	// shown as any.
+	Compact bool
+
+	// TypeAliases maps type names, as shown by Dump and the %#v verb, to
+	// shorter aliases displayed in their place, for example
+	// "pkg.LongTypeName": "LTN".  Names are replaced wherever they appear
+	// within a type, such as in map[string]pkg.LongTypeName.
+	TypeAliases map[string]string
+
+	// ShowSharing specifies that Dump should label values referenced by
+	// more than one pointer.  The first time such a value is displayed its
+	// pointer is labeled &N, and later pointers to it are labeled *N without
+	// displaying the value again.  Labels are numbered in the order they
+	// are displayed, so the output is deterministic when combined with
+	// DisablePointerAddresses.
+	ShowSharing bool
+
+	// TypeNames selects how type names are displayed: in full as reflect
+	// shows them, with import paths abbreviated, additionally with the type
+	// arguments of generic types elided, or as base names only.  See
+	// TypeNameMode for examples.  The default is TypeNameFull.
+	TypeNames TypeNameMode
+
+	// ByteFormat selects how Dump displays byte slices: as a hexdump, hex
+	// string, base64, Go literal, or quoted string.  See ByteFormat for the
+	// details of each.  The default is ByteHexdump.
+	ByteFormat ByteFormat
+
+	// ByteArrayFormat is the equivalent of ByteFormat for byte arrays, such
+	// as hashes and UUIDs.  The default is ByteHexdump.
+	ByteArrayFormat ByteFormat
+
+	// ByteWidth specifies the number of bytes per line of hexdumps and
+	// multi-line Go literals.  The default, 0, means 16 bytes per line.
+	ByteWidth int
+
+	// Theme specifies the ANSI escape sequences used by Dump to color type
+	// names, values, pointer addresses, and circular reference markers.
+	// See DefaultTheme.  Color is disabled by default.
+	Theme *Theme
+
+	// Format selects the layout used by the Dump family of functions.
+	// FormatJSON and FormatYAML walk values with the same rules as the
+	// default FormatSpew layout, including unexported fields, redaction,
+	// field filters, circular reference detection and map key sorting,
+	// but Theme and ShowSharing are not applied.
+	Format DumpFormat
+
+	// AnnotateTypes adds the type of each value to FormatJSON and
+	// FormatYAML output.
+	AnnotateTypes bool
+
+	// formatters holds the functions registered with RegisterFormatter.
+	// It is internal state rather than an option, so it is left out when
+	// a ConfigState is dumped.
+	formatters map[reflect.Type]func(io.Writer, reflect.Value) `trace:"-"`
 }
 
 // Config is the active configuration of the top-level functions.
@@ -282,6 +408,90 @@ func (c *ConfigState) Sdump(a ...interface{}) string {
 	return buf.String()
 }
 
+// FdumpGo formats and displays the passed arguments to io.Writer w.  It
+// formats exactly the same as DumpGo.
+func (c *ConfigState) FdumpGo(w io.Writer, a ...interface{}) {
+	fdumpGo(c, w, a...)
+}
+
+/*
+DumpGo displays the passed parameters to standard out as Go composite literals
+which can be pasted into Go source, such as a test case, and compiled.  Named
+types are used throughout, shared and circular pointers are assigned helper
+variables, and unexported fields are omitted with a comment noting each
+omission.
+
+The configuration options are controlled by modifying the public members
+of c.  Only the Indent option applies.
+
+See FdumpGo if you would prefer dumping to an arbitrary io.Writer or SdumpGo to
+get the formatted result as a string.
+*/
+func (c *ConfigState) DumpGo(a ...interface{}) {
+	fdumpGo(c, os.Stdout, a...)
+}
+
+// SdumpGo returns a string with the passed arguments formatted exactly the
+// same as DumpGo.
+func (c *ConfigState) SdumpGo(a ...interface{}) string {
+	var buf bytes.Buffer
+	fdumpGo(c, &buf, a...)
+	return buf.String()
+}
+
+// Fdiff formats and displays the differences between a and b to io.Writer w.
+// It formats exactly the same as Diff.
+func (c *ConfigState) Fdiff(w io.Writer, a, b interface{}) {
+	fdiff(c, w, a, b)
+}
+
+/*
+Diff displays the differences between a and b to standard out.  Both values
+are walked in parallel with the same reflection rules used by Dump and only
+the paths that differ are shown, each followed by the old and new values in a
+layout similar to a unified diff.
+
+The configuration options are controlled by modifying the public members
+of c.  See ConfigState for options documentation.
+
+See Fdiff if you would prefer the output on an arbitrary io.Writer or Sdiff to
+get the formatted result as a string.
+*/
+func (c *ConfigState) Diff(a, b interface{}) {
+	fdiff(c, os.Stdout, a, b)
+}
+
+// Sdiff returns a string with the differences between a and b formatted
+// exactly the same as Diff.
+func (c *ConfigState) Sdiff(a, b interface{}) string {
+	var buf bytes.Buffer
+	fdiff(c, &buf, a, b)
+	return buf.String()
+}
+
+// FdumpPath selects the sub-value of v at the passed path, as described by
+// Select, and displays it to io.Writer w formatted exactly the same as Dump.
+// The value is redacted if any struct field or map entry along the path is
+// redacted.  An error is returned, and nothing is written, when the path is
+// malformed or does not exist in v.
+func (c *ConfigState) FdumpPath(w io.Writer, v interface{}, path string) error {
+	return fdumpPath(c, w, v, path)
+}
+
+// Select selects the sub-value of v at the passed path as the package level
+// Select does.  A *PathError is also returned, instead of the value, when any
+// struct field or map entry along the path is redacted under c.
+func (c *ConfigState) Select(v interface{}, path string) (interface{}, error) {
+	rv, redacted, err := selectValue(c, v, path)
+	if err != nil || !rv.IsValid() {
+		return nil, err
+	}
+	if redacted {
+		return nil, &PathError{Path: path, Pos: path, Err: "redacted"}
+	}
+	return rv.Interface(), nil
+}
+
 // convertArgs accepts a slice of arguments and returns a slice of the same
 // length with each argument converted to a spew Formatter interface using
 // the ConfigState associated with s.
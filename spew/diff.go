@@ -0,0 +1,446 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	diffHeaderBytes   = []byte("--- a\n+++ b\n")
+	hunkBytes         = []byte("@@ ")
+	hunkCloseBytes    = []byte(" @@\n")
+	minusBytes        = []byte("- ")
+	plusSpaceBytes    = []byte("+ ")
+	noDifferenceBytes = []byte("<no differences>\n")
+)
+
+// diffState contains information about the state of a diff operation.
+type diffState struct {
+	w         io.Writer
+	visited   map[[2]uintptr]bool
+	changes   int
+	fieldPath []string
+	cs        *ConfigState
+
+	// hexInts and forceMethods carry the trace tag options of the field
+	// being compared, as in dumpState, so values are shown as Dump
+	// shows them.
+	hexInts      bool
+	forceMethods bool
+}
+
+// sdumpValue returns the dump of a single reflect value without the trailing
+// newline.
+func (d *diffState) sdumpValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<missing>"
+	}
+	var buf bytes.Buffer
+	ds := dumpState{w: &buf, cs: d.cs, hexInts: d.hexInts, forceMethods: d.forceMethods}
+	ds.pointers = make(map[uintptr]int)
+	ds.dump(v)
+	return buf.String()
+}
+
+// rendered returns whether Dump displays v as a whole, using a registered
+// formatter, a built-in renderer, a TraceDump method or, when methods are
+// enabled, an error or String method, rather than by walking it.  Such
+// values are compared by their displayed text.
+func (d *diffState) rendered(v reflect.Value, forceMethods bool) bool {
+	if d.cs.customFormatter(v) != nil {
+		return true
+	}
+	if d.cs.DisableMethods && !forceMethods {
+		return false
+	}
+	if kind := v.Kind(); kind == reflect.Ptr || kind == reflect.Interface {
+		return false
+	}
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return false
+		}
+		v = unsafeReflectValue(v)
+	}
+	for _, iface := range []reflect.Type{errorType, stringerType} {
+		if _, ok := d.cs.methodReceiver(v, iface); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// diffRedacted compares two redacted values and, if they differ, reports the
+// change with the redaction marker in place of each value.
+func (d *diffState) diffRedacted(path string, a, b reflect.Value) {
+	sub := diffState{w: ioutil.Discard, cs: d.cs}
+	sub.visited = make(map[[2]uintptr]bool)
+	sub.diff(path, a, b)
+	if sub.changes == 0 {
+		return
+	}
+
+	d.hunk(path)
+	for _, side := range []struct {
+		prefix []byte
+		v      reflect.Value
+	}{{minusBytes, a}, {plusSpaceBytes, b}} {
+		if side.v.IsValid() {
+			var buf bytes.Buffer
+			ds := dumpState{w: &buf, cs: d.cs}
+			ds.dumpRedacted(side.v)
+			d.writeLines(side.prefix, buf.String())
+		}
+	}
+}
+
+// diffLenOnly compares two values of a field tagged with the len option by
+// their lengths and capacities, which are all Dump shows of them.
+func (d *diffState) diffLenOnly(path string, a, b reflect.Value) {
+	var ab, bb bytes.Buffer
+	ads := dumpState{w: &ab, cs: d.cs}
+	ads.dumpLenOnly(a)
+	bds := dumpState{w: &bb, cs: d.cs}
+	bds.dumpLenOnly(b)
+	if ab.String() == bb.String() {
+		return
+	}
+	d.hunk(path)
+	d.writeLines(minusBytes, ab.String())
+	d.writeLines(plusSpaceBytes, bb.String())
+}
+
+// hunk writes the header for a changed path.  The header for the diff as a
+// whole is written before the first hunk.
+func (d *diffState) hunk(path string) {
+	if d.changes == 0 {
+		write(d.w, diffHeaderBytes)
+	}
+	d.changes++
+	if path == "" {
+		path = "."
+	}
+	write(d.w, hunkBytes)
+	write(d.w, []byte(path))
+	write(d.w, hunkCloseBytes)
+}
+
+// writeLines writes each line of s prefixed by prefix.
+func (d *diffState) writeLines(prefix []byte, s string) {
+	for _, line := range strings.Split(strings.TrimRight(s, "\n"), "\n") {
+		write(d.w, prefix)
+		write(d.w, []byte(line))
+		write(d.w, newlineBytes)
+	}
+}
+
+// report writes the old and new values for a changed path.  Invalid values
+// represent elements or keys that are only present on one side.
+func (d *diffState) report(path string, a, b reflect.Value) {
+	d.hunk(path)
+	if a.IsValid() {
+		d.writeLines(minusBytes, d.sdumpValue(a))
+	}
+	if b.IsValid() {
+		d.writeLines(plusSpaceBytes, d.sdumpValue(b))
+	}
+}
+
+// reportBytes writes the changes between two byte arrays or slices that
+// differ.  Bytes shown inline by the ByteFormat or ByteArrayFormat option are
+// reported as a whole, and hexdumped bytes as the lines of the hexdumps that
+// differ, with ByteWidth bytes per line.
+func (d *diffState) reportBytes(path string, a, b reflect.Value, ab, bb []byte) {
+	format := d.cs.byteFormat(a.Kind())
+	if format != ByteHexdump && (format != ByteString || utf8.Valid(ab) && utf8.Valid(bb)) {
+		d.report(path, a, b)
+		return
+	}
+
+	d.hunk(path)
+	width := d.cs.byteWidth()
+	al := strings.Split(strings.TrimRight(hexdump(ab, width), "\n"), "\n")
+	bl := strings.Split(strings.TrimRight(hexdump(bb, width), "\n"), "\n")
+	for i := 0; i < len(al) || i < len(bl); i++ {
+		switch {
+		case i >= len(bl):
+			d.writeLines(minusBytes, al[i])
+		case i >= len(al):
+			d.writeLines(plusSpaceBytes, bl[i])
+		case al[i] != bl[i]:
+			d.writeLines(minusBytes, al[i])
+			d.writeLines(plusSpaceBytes, bl[i])
+		}
+	}
+}
+
+// bytesOf returns the contents of a byte array or slice, or false if the
+// value does not hold bytes.
+func bytesOf(v reflect.Value) ([]byte, bool) {
+	if v.Type().Elem().Kind() != reflect.Uint8 {
+		return nil, false
+	}
+	buf := make([]byte, v.Len())
+	for i := range buf {
+		buf[i] = uint8(v.Index(i).Uint())
+	}
+	return buf, true
+}
+
+// keyPath returns the path element used for a map key.
+func (d *diffState) keyPath(key reflect.Value) string {
+	if key.Kind() == reflect.String {
+		return "[" + strconv.Quote(key.String()) + "]"
+	}
+	return "[" + d.cs.Sprint(key.Interface()) + "]"
+}
+
+// diff is the main workhorse for comparing values.  It walks both values in
+// parallel using the same reflection rules as dumpState, and reports every
+// path at which they differ.  Circular data structures are detected by
+// remembering the pairs of pointers already compared.
+func (d *diffState) diff(path string, a, b reflect.Value) {
+	if a.Kind() == reflect.Interface && !a.IsNil() {
+		a = a.Elem()
+	}
+	if b.Kind() == reflect.Interface && !b.IsNil() {
+		b = b.Elem()
+	}
+	if !a.IsValid() || !b.IsValid() {
+		if a.IsValid() != b.IsValid() {
+			d.report(path, a, b)
+		}
+		return
+	}
+	if a.Type() != b.Type() {
+		d.report(path, a, b)
+		return
+	}
+
+	forceMethods := d.forceMethods
+	d.forceMethods = false
+	if d.rendered(a, forceMethods) {
+		d.forceMethods = forceMethods
+		if d.sdumpValue(a) != d.sdumpValue(b) {
+			d.report(path, a, b)
+		}
+		d.forceMethods = false
+		return
+	}
+
+	switch a.Kind() {
+	case reflect.Bool:
+		if a.Bool() != b.Bool() {
+			d.report(path, a, b)
+		}
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		if a.Int() != b.Int() {
+			d.report(path, a, b)
+		}
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint, reflect.Uintptr:
+		if a.Uint() != b.Uint() {
+			d.report(path, a, b)
+		}
+
+	case reflect.Float32, reflect.Float64:
+		af, bf := a.Float(), b.Float()
+		if af != bf && !(math.IsNaN(af) && math.IsNaN(bf)) {
+			d.report(path, a, b)
+		}
+
+	case reflect.Complex64, reflect.Complex128:
+		if a.Complex() != b.Complex() {
+			d.report(path, a, b)
+		}
+
+	case reflect.String:
+		if a.String() != b.String() {
+			d.report(path, a, b)
+		}
+
+	case reflect.Interface:
+		// Both are nil interfaces since non-nil ones were unpacked above.
+
+	case reflect.Ptr:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				d.report(path, a, b)
+			}
+			return
+		}
+		pair := [2]uintptr{a.Pointer(), b.Pointer()}
+		if pair[0] == pair[1] || d.visited[pair] {
+			return
+		}
+		d.visited[pair] = true
+		d.forceMethods = forceMethods
+		d.diff(path, a.Elem(), b.Elem())
+
+	case reflect.Slice:
+		if a.IsNil() != b.IsNil() {
+			d.report(path, a, b)
+			return
+		}
+		fallthrough
+
+	case reflect.Array:
+		if ab, ok := bytesOf(a); ok {
+			bb, _ := bytesOf(b)
+			if !bytes.Equal(ab, bb) {
+				d.reportBytes(path, a, b, ab, bb)
+			}
+			return
+		}
+		for i := 0; i < a.Len() || i < b.Len(); i++ {
+			elemPath := path + "[" + strconv.Itoa(i) + "]"
+			switch {
+			case i >= b.Len():
+				d.report(elemPath, a.Index(i), reflect.Value{})
+			case i >= a.Len():
+				d.report(elemPath, reflect.Value{}, b.Index(i))
+			default:
+				d.diff(elemPath, a.Index(i), b.Index(i))
+			}
+		}
+
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() {
+			d.report(path, a, b)
+			return
+		}
+		keys := a.MapKeys()
+		for _, key := range b.MapKeys() {
+			if !a.MapIndex(key).IsValid() {
+				keys = append(keys, key)
+			}
+		}
+		for i := range keys {
+			keys[i] = unsafeReflectValue(keys[i])
+		}
+		sortValues(keys, d.cs)
+		for _, key := range keys {
+			av, bv := a.MapIndex(key), b.MapIndex(key)
+			if d.cs.redactKey(key, d.fieldPath, av) {
+				d.diffRedacted(path+d.keyPath(key), av, bv)
+			} else {
+				d.diff(path+d.keyPath(key), av, bv)
+			}
+		}
+
+	case reflect.Struct:
+		vt := a.Type()
+		for _, i := range d.cs.visibleFields(a, d.fieldPath, false) {
+			vtf := vt.Field(i)
+			tag := parseFieldTag(vtf)
+			fieldPath := path + "." + vtf.Name
+			d.fieldPath = append(d.fieldPath, vtf.Name)
+			af, bf := a.Field(i), b.Field(i)
+			switch {
+			case d.cs.redactField(vtf, d.fieldPath, af) || d.cs.redactField(vtf, d.fieldPath, bf):
+				d.diffRedacted(fieldPath, af, bf)
+			case tag.lenOnly && hasLen(af.Kind()):
+				d.diffLenOnly(fieldPath, af, bf)
+			default:
+				savedHex := d.hexInts
+				d.hexInts = d.hexInts || tag.hex
+				d.forceMethods = tag.str
+				d.diff(fieldPath, af, bf)
+				d.hexInts = savedHex
+				d.forceMethods = false
+			}
+			d.fieldPath = d.fieldPath[:len(d.fieldPath)-1]
+		}
+
+	case reflect.UnsafePointer, reflect.Chan, reflect.Func:
+		if a.Pointer() != b.Pointer() {
+			d.report(path, a, b)
+		}
+	}
+}
+
+// fdiff is a helper function to consolidate the logic from the various public
+// methods which take varying writers and config states.
+func fdiff(cs *ConfigState, w io.Writer, a, b interface{}) {
+	d := diffState{w: w, cs: cs}
+	d.visited = make(map[[2]uintptr]bool)
+	d.diff("", reflect.ValueOf(a), reflect.ValueOf(b))
+	if d.changes == 0 {
+		write(w, noDifferenceBytes)
+	}
+}
+
+// Fdiff formats and displays the differences between a and b to io.Writer w.
+// It formats exactly the same as Diff.
+func Fdiff(w io.Writer, a, b interface{}) {
+	fdiff(&Config, w, a, b)
+}
+
+// Sdiff returns a string with the differences between a and b formatted
+// exactly the same as Diff.
+func Sdiff(a, b interface{}) string {
+	var buf bytes.Buffer
+	fdiff(&Config, &buf, a, b)
+	return buf.String()
+}
+
+/*
+Diff displays the differences between a and b to standard out.  Both values
+are walked in parallel with the same reflection rules used by Dump:
+
+	* Pointers are dereferenced and followed
+	* Circular data structures are detected and handled properly
+	* Map keys are sorted so the output is stable
+	* Unexported fields are compared, using unsafe where available
+	* Values Dump displays with a registered formatter, a renderer, a
+	  TraceDump method or, when methods are enabled, an error or String
+	  method are compared by their displayed text rather than their fields
+	* Struct tags are honoured, so skipped fields are not compared and
+	  fields shown only by length are compared by length
+	* Hexdumped byte arrays and slices are compared line by line, with
+	  ByteWidth bytes per line, and only the differing lines are shown;
+	  bytes shown inline by ByteFormat or ByteArrayFormat are compared as a
+	  whole
+
+Only the paths that differ are shown, each followed by the old value prefixed
+with "- " and the new value prefixed with "+ ", similar to a unified diff:
+
+	--- a
+	+++ b
+	@@ .Name @@
+	- (string) (len=3) "foo"
+	+ (string) (len=3) "bar"
+
+The configuration options are controlled by an exported package global,
+spew.Config.  See ConfigState for options documentation.
+
+See Fdiff if you would prefer the output on an arbitrary io.Writer or Sdiff to
+get the formatted result as a string.
+*/
+func Diff(a, b interface{}) {
+	fdiff(&Config, os.Stdout, a, b)
+}
@@ -31,6 +31,8 @@ printing facilities for Go data types are as follows:
 	* Byte arrays and slices are dumped like the hexdump -C command which
 	  includes offsets, byte values in hex, and ASCII output (only when using
 	  Dump style)
+	* Channels show their length and capacity, and functions show their
+	  name and the file and line defining them
 
 There are two different approaches spew allows for dumping Go data structures:
 
@@ -82,6 +84,25 @@ The following configuration options are available:
 		Maximum number of levels to descend into nested data structures.
 		There is no limit by default.
 
+	* MaxStringLen
+		Maximum number of bytes of each string to display.  There is no
+		limit by default.
+
+	* MaxElements
+		Maximum number of elements of each slice, array, and map to
+		display, followed by "... N more" when elements are left out.
+		There is no limit by default.
+
+	* MaxHexdumpBytes
+		Maximum number of bytes of each byte slice or array to display.
+		There is no limit by default.
+
+	* MaxOutputBytes
+		Maximum number of bytes written by a single Dump call or by the
+		custom formatter for a single argument, after which the output
+		is cut off with "... <output truncated>" and the rest of the
+		value is not visited.  There is no limit by default.
+
 	* DisableMethods
 		Disables invocation of error and Stringer interface methods.
 		Method invocation is enabled by default.
@@ -118,6 +139,165 @@ The following configuration options are available:
 		spewed to strings and sorted by those strings.  This is only
 		considered if SortKeys is true.
 
+	* IncludeFields
+		Patterns for the dot-separated field paths, such as "Header.*",
+		"Body", or "*.password", of the struct fields to display.  A "**"
+		element, or a leading "*", matches any number of field names, so
+		"*.password" matches a password field at any depth.  All fields
+		are displayed by default.
+
+	* ExcludeFields
+		Patterns, in the same form as IncludeFields, for the struct
+		fields not to display.  No fields are excluded by default.
+
+	* RedactFields
+		Case-insensitive patterns for struct field names and string map
+		keys whose values are displayed as <redacted len=N>.  Patterns
+		match whole words of a name, so "token" matches AuthToken but
+		not MaxTokens.  Fields
+		tagged with `trace:"redact"` are always redacted.  Only tagged
+		fields are redacted by default.
+
+	* Redactor
+		A Redactor consulted for every struct field and string keyed
+		map entry to decide whether its value is redacted.  There is
+		no Redactor by default.
+
+	* Compact
+		Specifies that Dump displays each value on a single line while
+		still showing types, lengths, capacities, and pointer chains.
+
+	* TypeAliases
+		Maps type names to shorter aliases displayed in their place.
+		There are no aliases by default.
+
+	* ShowSharing
+		Specifies that Dump labels values referenced by more than one
+		pointer with &N where they are displayed and *N where they are
+		referenced again, rather than displaying them repeatedly.
+		Sharing is not shown by default.
+
+	* TypeNames
+		How type names are displayed: TypeNameFull, TypeNameShort,
+		TypeNameCompact, or TypeNameBase.  Type names are displayed in
+		full, including the import paths of type arguments, by default.
+
+	* ByteFormat
+		How byte slices are displayed: ByteHexdump, ByteHex, ByteBase64,
+		ByteGoLiteral, or ByteString.  Byte slices are hexdumped by
+		default.
+
+	* ByteArrayFormat
+		How byte arrays, such as hashes and UUIDs, are displayed.  Byte
+		arrays are hexdumped by default.
+
+	* ByteWidth
+		Number of bytes per line of hexdumps and multi-line Go literals.
+		The default is 16.
+
+	* Theme
+		The color theme used by Dump to color type names, values,
+		pointer addresses, and circular reference markers with ANSI
+		escape sequences.  Color is disabled by default.
+
+	* Renderers
+		Flags selecting the built-in renderers which display time.Time,
+		time.Duration, big.Int, big.Float, big.Rat, net.IP, net.IPNet,
+		net.HardwareAddr, url.URL, sync.Mutex, sync.RWMutex,
+		sync.WaitGroup, sync.Once, the sync/atomic types, and
+		reflect.Value values in a readable form instead of their
+		internal fields.  The renderers are used even when
+		DisableMethods is set.  No renderers are enabled by default.
+
+	* Format
+		Selects the layout of the Dump family of functions: the default
+		FormatSpew, FormatJSON or FormatYAML.  See JSON and YAML Output
+		below.
+
+	* AnnotateTypes
+		Adds the type of each value to FormatJSON and FormatYAML output.
+
+Struct Tags
+
+The display of individual struct fields can be controlled with a trace struct
+tag holding a comma-separated list of options:
+
+	-          skip the field
+	omitempty  skip the field when it holds a zero or empty value
+	hex        display integers in hexadecimal
+	len        display only the length of strings, slices, arrays, maps
+	           and channels
+	string     display the result of the String or Error method even
+	           when DisableMethods is set
+	name=NAME  display the field as NAME
+	redact     display the field as <redacted len=N>
+
+For example:
+
+	type Conn struct {
+		ID     uint32 `trace:"hex,name=id"`
+		Buf    []byte `trace:"len"`
+		State  State  `trace:"string"`
+		cache  *Cache `trace:"-"`
+		Secret string `trace:"redact"`
+	}
+
+Per-Type Formatters
+
+The generic reflection based display of a type can be replaced, in both Dump
+and the custom formatter, by registering a function for the type:
+
+	spew.Config.RegisterFormatter(reflect.TypeOf(Token{}),
+		func(w io.Writer, v reflect.Value) {
+			fmt.Fprintf(w, "token %d", v.Field(0).Int())
+		})
+
+Alternatively, a type may implement the TraceDumper interface:
+
+	func (t Token) TraceDump(w io.Writer) {
+		fmt.Fprintf(w, "token %d", t.id)
+	}
+
+Registered formatters take precedence over the built-in renderers enabled by
+the Renderers option, which take precedence over TraceDump methods.  All are
+used regardless of the DisableMethods option.  The type of the value is still
+displayed before the output of the formatter.
+
+Snapshots
+
+Walking a value which another goroutine is changing races with the writer and
+may display torn state.  A type guarded by a lock can implement the
+Snapshotter interface to return a consistent copy, which is displayed in place
+of the value:
+
+	func (c *Cache) Snapshot() interface{} {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return Cache{entries: c.copyEntries()}
+	}
+
+Snapshot should return a value of the same type so the displayed type matches
+the value.  It is not called again for values of the same type found within
+the snapshot.
+
+JSON and YAML Output
+
+Setting the Format option to FormatJSON or FormatYAML makes the Dump family of
+functions output each value as a JSON or YAML document instead.  Values are
+walked with the same rules as the default layout, so unlike encoding/json the
+output includes unexported fields, stops at circular references with an
+"<already shown>" marker, and honors redaction, the field filters, the struct
+tag options, the size limits and the SortKeys and SpewKeys options.  Values
+with no JSON or YAML equivalent, such as channels, functions and the output
+of Stringer methods and formatters, are output as strings.
+
+The Compact option selects single line JSON, or YAML flow style.  With the
+AnnotateTypes option, JSON and YAML flow style values are wrapped in an object
+holding their type and value, while YAML block style values are followed by a
+comment holding their type:
+
+	Name: x # string
+
 Dump Usage
 
 Simply call spew.Dump with a list of variables you want to dump:
@@ -156,6 +336,45 @@ command as shown.
 	 00000020  31 32                                             |12|
 	}
 
+The ByteFormat and ByteArrayFormat options select other renderings, for
+example a SHA-1 hash in a [20]byte array with ByteArrayFormat set to ByteHex:
+	([20]uint8) (len=20 cap=20) 2fd4e1c67a2d28fced849ee1bb76e7391b93eb12
+
+Go Syntax Dump
+
+DumpGo, FdumpGo, and SdumpGo display values as Go composite literals which
+can be pasted into a test case and compiled.  Shared and circular pointers are
+assigned helper variables inside a function literal, and unexported fields are
+omitted with a comment since they cannot be set outside of their package:
+
+	func() *main.Node {
+	 p1 := new(main.Node)
+	 *p1 = main.Node{
+	  Name: "loop",
+	  Next: p1,
+	  // id: unexported field omitted
+	 }
+	 return p1
+	}()
+
+Diff
+
+Diff, Fdiff, and Sdiff walk two values in parallel and show only the paths at
+which they differ, with the old and new values in a layout similar to a
+unified diff:
+
+	--- a
+	+++ b
+	@@ .Tags[1] @@
+	- (string) (len=3) "old"
+	+ (string) (len=3) "new"
+	@@ .Limits["max"] @@
+	+ (int) 10
+
+Values are shown as Dump shows them, so values displayed by a formatter,
+renderer or method, such as time.Time, are compared by their displayed text
+rather than by their internal fields.
+
 Custom Formatter
 
 Spew provides a custom formatter that implements the fmt.Formatter interface
@@ -166,7 +385,8 @@ standard %v format specifier.
 The custom formatter only responds to the %v (most compact), %+v (adds pointer
 addresses), %#v (adds types), or %#+v (adds types and pointer addresses) verb
 combinations.  Any other verbs such as %x and %q will be sent to the the
-standard fmt package for formatting.  In addition, the custom formatter ignores
+standard fmt package for formatting, with redacted values replaced as by
+ConfigState.Redacted.  In addition, the custom formatter ignores
 the width and precision arguments (however they will still work on the format
 specifiers not handled by the custom formatter).
 
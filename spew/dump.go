@@ -18,7 +18,6 @@ package spew
 
 import (
 	"bytes"
-	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -33,6 +32,10 @@ var (
 	// convert cgo types to uint8 slices for hexdumping.
 	uint8Type = reflect.TypeOf(uint8(0))
 
+	// interfaceType is a reflect.Type representing the empty interface.  It
+	// is used to display nil arguments.
+	interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
 	// cCharRE is a regular expression that matches a cgo char.
 	// It is used to detect character arrays to hexdump them.
 	cCharRE = regexp.MustCompile(`^.*\._Ctype_char$`)
@@ -54,19 +57,77 @@ type dumpState struct {
 	pointers         map[uintptr]int
 	ignoreNextType   bool
 	ignoreNextIndent bool
+	fieldPath        []string
+	hexInts          bool
+	forceMethods     bool
+	refs             map[ptrKey]int
+	labels           map[ptrKey]int
+	snapshots        map[reflect.Type]bool
 	cs               *ConfigState
 }
 
 // indent performs indentation according to the depth level and cs.Indent
-// option.
+// option.  There is no indentation in compact mode.
 func (d *dumpState) indent() {
 	if d.ignoreNextIndent {
 		d.ignoreNextIndent = false
 		return
 	}
+	if d.cs.Compact {
+		return
+	}
 	d.Write(bytes.Repeat([]byte(d.cs.Indent), d.depth))
 }
 
+// openBrace writes the opening brace of a slice, array, map, or struct,
+// followed by a newline unless in compact mode.
+func (d *dumpState) openBrace() {
+	if d.cs.Compact {
+		d.Write(openBraceBytes)
+		return
+	}
+	d.Write(openBraceNewlineBytes)
+}
+
+// endElement writes the separator following an element of a slice, array,
+// map, or struct.  Each element is on its own line unless in compact mode,
+// where elements are separated by a comma and a space.
+func (d *dumpState) endElement(last bool) {
+	switch {
+	case d.cs.Compact && last:
+	case d.cs.Compact:
+		d.Write(commaSpaceBytes)
+	case last:
+		d.Write(newlineBytes)
+	default:
+		d.Write(commaNewlineBytes)
+	}
+}
+
+// dumpMore writes the marker for n elements, or bytes when isBytes is set,
+// left out due to a size limit.
+func (d *dumpState) dumpMore(n int, isBytes bool) {
+	if d.cs.Compact {
+		d.Write(commaSpaceBytes)
+		printMore(d.w, n, isBytes)
+		return
+	}
+	d.indent()
+	printMore(d.w, n, isBytes)
+	d.Write(newlineBytes)
+}
+
+// maxDepthReached writes the marker for a value nested deeper than the
+// MaxDepth option.
+func (d *dumpState) maxDepthReached() {
+	if d.cs.Compact {
+		d.Write(maxShortBytes)
+		return
+	}
+	d.indent()
+	d.Write(maxNewlineBytes)
+}
+
 // unpackValue returns values inside of non-nil interfaces when possible.
 // This is useful for data types like structs, arrays, slices, and maps which
 // can contain varying types packed inside an interface.
@@ -95,8 +156,10 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 	// references.
 	nilFound := false
 	cycleFound := false
+	sharedLabel := 0
 	indirects := 0
 	ve := v
+	var lastPtr reflect.Value
 	for ve.Kind() == reflect.Ptr {
 		if ve.IsNil() {
 			nilFound = true
@@ -105,6 +168,11 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 		indirects++
 		addr := ve.Pointer()
 		pointerChain = append(pointerChain, addr)
+		if label, ok := d.labels[ptrKey{addr, ve.Type()}]; ok {
+			sharedLabel = label
+			indirects--
+			break
+		}
 		if pd, ok := d.pointers[addr]; ok && pd < d.depth {
 			cycleFound = true
 			indirects--
@@ -112,6 +180,7 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 		}
 		d.pointers[addr] = d.depth
 
+		lastPtr = ve
 		ve = ve.Elem()
 		if ve.Kind() == reflect.Interface {
 			if ve.IsNil() {
@@ -122,32 +191,57 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 		}
 	}
 
+	// Label the value when the ShowSharing option is set and the last
+	// pointer is referenced more than once.
+	newLabel := 0
+	if !nilFound && !cycleFound && sharedLabel == 0 && lastPtr.IsValid() {
+		if k := (ptrKey{lastPtr.Pointer(), lastPtr.Type()}); d.refs[k] > 1 {
+			newLabel = len(d.labels) + 1
+			d.labels[k] = newLabel
+		}
+	}
+
 	// Display type information.
-	d.Write(openParenBytes)
-	d.Write(bytes.Repeat(asteriskBytes, indirects))
-	d.Write([]byte(ve.Type().String()))
-	d.Write(closeParenBytes)
+	d.writeType(indirects, ve.Type())
 
 	// Display pointer information.
-	if !d.cs.DisablePointerAddresses && len(pointerChain) > 0 {
+	showAddrs := !d.cs.DisablePointerAddresses && len(pointerChain) > 0
+	if showAddrs || newLabel != 0 || sharedLabel != 0 {
 		d.Write(openParenBytes)
-		for i, addr := range pointerChain {
-			if i > 0 {
-				d.Write(pointerChainBytes)
+		if showAddrs {
+			for i, addr := range pointerChain {
+				if i > 0 {
+					d.Write(pointerChainBytes)
+				}
+				d.setColor(d.cs.theme().Pointer)
+				printHexPtr(d.w, addr)
+				d.resetColor(d.cs.theme().Pointer)
 			}
-			printHexPtr(d.w, addr)
+		}
+		switch {
+		case newLabel != 0:
+			d.writeLabel(showAddrs, ampersandBytes, newLabel)
+		case sharedLabel != 0:
+			d.writeLabel(showAddrs, asteriskBytes, sharedLabel)
 		}
 		d.Write(closeParenBytes)
 	}
 
+	// A shared value is only displayed where it is labeled.
+	if sharedLabel != 0 {
+		return
+	}
+
 	// Display dereferenced value.
 	d.Write(openParenBytes)
 	switch {
 	case nilFound:
-		d.Write(nilAngleBytes)
+		d.writeNil()
 
 	case cycleFound:
+		d.setColor(d.cs.theme().Cycle)
 		d.Write(circularBytes)
+		d.resetColor(d.cs.theme().Cycle)
 
 	default:
 		d.ignoreNextType = true
@@ -156,9 +250,77 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 	d.Write(closeParenBytes)
 }
 
-// dumpSlice handles formatting of arrays and slices.  Byte (uint8 under
-// reflection) arrays and slices are dumped in hexdump -C fashion.
-func (d *dumpState) dumpSlice(v reflect.Value) {
+// writeType displays the type t, preceded by the passed number of pointer
+// indirections, in parentheses.
+func (d *dumpState) writeType(indirects int, t reflect.Type) {
+	d.setColor(d.cs.theme().Type)
+	d.Write(openParenBytes)
+	d.Write(bytes.Repeat(asteriskBytes, indirects))
+	d.Write([]byte(d.cs.typeName(t)))
+	d.Write(closeParenBytes)
+	d.resetColor(d.cs.theme().Type)
+}
+
+// writeNil displays the marker for nil values.
+func (d *dumpState) writeNil() {
+	d.setColor(d.cs.theme().Nil)
+	d.Write(nilAngleBytes)
+	d.resetColor(d.cs.theme().Nil)
+}
+
+// dumpLenCap displays the length and capacity of the value if the built-in
+// len and cap functions work with the value's kind.  Zero lengths and
+// capacities are only displayed when always is set.
+func (d *dumpState) dumpLenCap(v reflect.Value, always bool) {
+	valueLen, valueCap, hasCap := 0, 0, false
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Chan:
+		valueLen, valueCap, hasCap = v.Len(), v.Cap(), true
+	case reflect.Map, reflect.String:
+		valueLen = v.Len()
+	}
+	showLen := valueLen != 0 || always
+	showCap := !d.cs.DisableCapacities && hasCap && (valueCap != 0 || always)
+	if showLen || showCap {
+		d.Write(openParenBytes)
+		if showLen {
+			d.Write(lenEqualsBytes)
+			printInt(d.w, int64(valueLen), 10)
+		}
+		if showCap {
+			if showLen {
+				d.Write(spaceBytes)
+			}
+			d.Write(capEqualsBytes)
+			printInt(d.w, int64(valueCap), 10)
+		}
+		d.Write(closeParenBytes)
+		d.Write(spaceBytes)
+	}
+}
+
+// dumpLenOnly displays the type, length, and capacity of a value tagged with
+// the len option in place of its contents.
+func (d *dumpState) dumpLenOnly(v reflect.Value) {
+	d.writeType(0, v.Type())
+	d.Write(spaceBytes)
+	d.dumpLenCap(v, true)
+	d.Write(elidedBytes)
+}
+
+// dumpRedacted displays the type of a redacted value followed by the
+// redaction marker in place of the value.
+func (d *dumpState) dumpRedacted(v reflect.Value) {
+	d.writeType(0, v.Type())
+	d.Write(spaceBytes)
+	printRedacted(d.w, v)
+}
+
+// byteContents returns the contents of a byte (uint8 under reflection) or cgo
+// char array or slice, or false if v holds other elements.  It uses the
+// underlying data where possible, and falls back to converting and copying
+// each element.
+func byteContents(v reflect.Value) ([]uint8, bool) {
 	// Determine whether this type should be hex dumped or not.  Also,
 	// for types which should be hexdumped, try to use the underlying data
 	// first, then fall back to trying to convert them to a uint8 slice.
@@ -222,25 +384,46 @@ func (d *dumpState) dumpSlice(v reflect.Value) {
 			doHexDump = true
 		}
 	}
+	return buf, doHexDump
+}
 
-	// Hexdump the entire slice as needed.
+// dumpSlice handles formatting of arrays and slices.  Byte (uint8 under
+// reflection) arrays and slices are dumped in hexdump -C fashion.
+func (d *dumpState) dumpSlice(v reflect.Value) {
+	buf, doHexDump := byteContents(v)
+	numEntries := v.Len()
+
+	// Hexdump the slice, up to the MaxHexdumpBytes limit, as needed.  The
+	// compact mode shows the bytes in hex on a single line instead.
 	if doHexDump {
+		numBytes := d.cs.limitBytes(len(buf))
+		if d.cs.Compact {
+			fmt.Fprintf(d, "% x", buf[:numBytes])
+			if numBytes < len(buf) {
+				d.Write(spaceBytes)
+				printMore(d.w, len(buf)-numBytes, true)
+			}
+			return
+		}
 		indent := strings.Repeat(d.cs.Indent, d.depth)
-		str := indent + hex.Dump(buf)
+		str := indent + hexdump(buf[:numBytes], d.cs.byteWidth())
 		str = strings.Replace(str, "\n", "\n"+indent, -1)
 		str = strings.TrimRight(str, d.cs.Indent)
 		d.Write([]byte(str))
+		if numBytes < len(buf) {
+			d.dumpMore(len(buf)-numBytes, true)
+		}
 		return
 	}
 
-	// Recursively call dump for each item.
-	for i := 0; i < numEntries; i++ {
+	// Recursively call dump for each item up to the MaxElements limit.
+	numShown := d.cs.limitElements(numEntries)
+	for i := 0; i < numShown && !outputFull(d.w); i++ {
 		d.dump(d.unpackValue(v.Index(i)))
-		if i < (numEntries - 1) {
-			d.Write(commaNewlineBytes)
-		} else {
-			d.Write(newlineBytes)
-		}
+		d.endElement(i == numShown-1)
+	}
+	if numShown < numEntries {
+		d.dumpMore(numEntries-numShown, false)
 	}
 }
 
@@ -249,6 +432,11 @@ func (d *dumpState) dumpSlice(v reflect.Value) {
 // appropriately.  It is a recursive function, however circular data structures
 // are detected and handled properly.
 func (d *dumpState) dump(v reflect.Value) {
+	// Stop once the MaxOutputBytes limit has been reached.
+	if outputFull(d.w) {
+		return
+	}
+
 	// Handle invalid reflect values immediately.
 	kind := v.Kind()
 	if kind == reflect.Invalid {
@@ -256,6 +444,34 @@ func (d *dumpState) dump(v reflect.Value) {
 		return
 	}
 
+	// Display a consistent copy of values which implement Snapshotter.
+	if d.snapshots == nil {
+		d.snapshots = make(map[reflect.Type]bool)
+	}
+	if snap, done, ok := d.cs.snapshot(v, d.snapshots); ok {
+		defer done()
+		v = snap
+		if kind = v.Kind(); kind == reflect.Invalid {
+			d.indent()
+			d.writeNil()
+			return
+		}
+	}
+
+	// Use a registered formatter or TraceDump method in place of the
+	// generic display when one exists.
+	if custom := d.cs.customFormatter(v); custom != nil {
+		if !d.ignoreNextType {
+			d.indent()
+			d.writeType(0, v.Type())
+			d.Write(spaceBytes)
+		}
+		d.ignoreNextType = false
+		d.forceMethods = false
+		custom(d.w)
+		return
+	}
+
 	// Handle pointers specially.
 	if kind == reflect.Ptr {
 		d.indent()
@@ -266,42 +482,21 @@ func (d *dumpState) dump(v reflect.Value) {
 	// Print type information unless already handled elsewhere.
 	if !d.ignoreNextType {
 		d.indent()
-		d.Write(openParenBytes)
-		d.Write([]byte(v.Type().String()))
-		d.Write(closeParenBytes)
+		d.writeType(0, v.Type())
 		d.Write(spaceBytes)
 	}
 	d.ignoreNextType = false
 
 	// Display length and capacity if the built-in len and cap functions
-	// work with the value's kind and the len/cap itself is non-zero.
-	valueLen, valueCap := 0, 0
-	switch v.Kind() {
-	case reflect.Array, reflect.Slice, reflect.Chan:
-		valueLen, valueCap = v.Len(), v.Cap()
-	case reflect.Map, reflect.String:
-		valueLen = v.Len()
-	}
-	if valueLen != 0 || !d.cs.DisableCapacities && valueCap != 0 {
-		d.Write(openParenBytes)
-		if valueLen != 0 {
-			d.Write(lenEqualsBytes)
-			printInt(d.w, int64(valueLen), 10)
-		}
-		if !d.cs.DisableCapacities && valueCap != 0 {
-			if valueLen != 0 {
-				d.Write(spaceBytes)
-			}
-			d.Write(capEqualsBytes)
-			printInt(d.w, int64(valueCap), 10)
-		}
-		d.Write(closeParenBytes)
-		d.Write(spaceBytes)
-	}
+	// work with the value's kind and the len/cap itself is non-zero.  They
+	// are always displayed for channels to distinguish unbuffered ones.
+	d.dumpLenCap(v, kind == reflect.Chan && !v.IsNil())
 
 	// Call Stringer/error interfaces if they exist and the handle methods flag
-	// is enabled
-	if !d.cs.DisableMethods {
+	// is enabled or the field being dumped is tagged with the string option.
+	forceMethods := d.forceMethods
+	d.forceMethods = false
+	if !d.cs.DisableMethods || forceMethods {
 		if (kind != reflect.Invalid) && (kind != reflect.Interface) {
 			if handled := handleMethods(d.cs, d.w, v); handled {
 				return
@@ -318,36 +513,58 @@ func (d *dumpState) dump(v reflect.Value) {
 		printBool(d.w, v.Bool())
 
 	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
-		printInt(d.w, v.Int(), 10)
+		d.setColor(d.cs.theme().Number)
+		if d.hexInts {
+			printHexInt(d.w, v.Int())
+		} else {
+			printInt(d.w, v.Int(), 10)
+		}
+		d.resetColor(d.cs.theme().Number)
 
 	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
-		printUint(d.w, v.Uint(), 10)
+		d.setColor(d.cs.theme().Number)
+		if d.hexInts {
+			printHexUint(d.w, v.Uint())
+		} else {
+			printUint(d.w, v.Uint(), 10)
+		}
+		d.resetColor(d.cs.theme().Number)
 
 	case reflect.Float32:
+		d.setColor(d.cs.theme().Number)
 		printFloat(d.w, v.Float(), 32)
+		d.resetColor(d.cs.theme().Number)
 
 	case reflect.Float64:
+		d.setColor(d.cs.theme().Number)
 		printFloat(d.w, v.Float(), 64)
+		d.resetColor(d.cs.theme().Number)
 
 	case reflect.Complex64:
+		d.setColor(d.cs.theme().Number)
 		printComplex(d.w, v.Complex(), 32)
+		d.resetColor(d.cs.theme().Number)
 
 	case reflect.Complex128:
+		d.setColor(d.cs.theme().Number)
 		printComplex(d.w, v.Complex(), 64)
+		d.resetColor(d.cs.theme().Number)
 
 	case reflect.Slice:
 		if v.IsNil() {
-			d.Write(nilAngleBytes)
+			d.writeNil()
 			break
 		}
 		fallthrough
 
 	case reflect.Array:
-		d.Write(openBraceNewlineBytes)
+		if d.dumpBytes(v) {
+			break
+		}
+		d.openBrace()
 		d.depth++
 		if (d.cs.MaxDepth != 0) && (d.depth > d.cs.MaxDepth) {
-			d.indent()
-			d.Write(maxNewlineBytes)
+			d.maxDepthReached()
 		} else {
 			d.dumpSlice(v)
 		}
@@ -356,13 +573,19 @@ func (d *dumpState) dump(v reflect.Value) {
 		d.Write(closeBraceBytes)
 
 	case reflect.String:
-		d.Write([]byte(strconv.Quote(v.String())))
+		str, more := d.cs.limitString(v.String())
+		d.setColor(d.cs.theme().String)
+		d.Write([]byte(strconv.Quote(str)))
+		d.resetColor(d.cs.theme().String)
+		if more > 0 {
+			printMore(d.w, more, true)
+		}
 
 	case reflect.Interface:
 		// The only time we should get here is for nil interfaces due to
 		// unpackValue calls.
 		if v.IsNil() {
-			d.Write(nilAngleBytes)
+			d.writeNil()
 		}
 
 	case reflect.Ptr:
@@ -372,31 +595,37 @@ func (d *dumpState) dump(v reflect.Value) {
 	case reflect.Map:
 		// nil maps should be indicated as different than empty maps
 		if v.IsNil() {
-			d.Write(nilAngleBytes)
+			d.writeNil()
 			break
 		}
 
-		d.Write(openBraceNewlineBytes)
+		d.openBrace()
 		d.depth++
 		if (d.cs.MaxDepth != 0) && (d.depth > d.cs.MaxDepth) {
-			d.indent()
-			d.Write(maxNewlineBytes)
+			d.maxDepthReached()
 		} else {
-			numEntries := v.Len()
 			keys := v.MapKeys()
 			if d.cs.SortKeys {
 				sortValues(keys, d.cs)
 			}
-			for i, key := range keys {
-				d.dump(d.unpackValue(key))
+			numShown := d.cs.limitElements(len(keys))
+			for i, key := range keys[:numShown] {
+				if outputFull(d.w) {
+					break
+				}
+				key = d.unpackValue(key)
+				d.dump(key)
 				d.Write(colonSpaceBytes)
-				d.ignoreNextIndent = true
-				d.dump(d.unpackValue(v.MapIndex(key)))
-				if i < (numEntries - 1) {
-					d.Write(commaNewlineBytes)
+				if mv := v.MapIndex(key); d.cs.redactKey(key, d.fieldPath, mv) {
+					d.dumpRedacted(mv)
 				} else {
-					d.Write(newlineBytes)
+					d.ignoreNextIndent = true
+					d.dump(d.unpackValue(mv))
 				}
+				d.endElement(i == numShown-1)
+			}
+			if numShown < len(keys) {
+				d.dumpMore(len(keys)-numShown, false)
 			}
 		}
 		d.depth--
@@ -404,26 +633,40 @@ func (d *dumpState) dump(v reflect.Value) {
 		d.Write(closeBraceBytes)
 
 	case reflect.Struct:
-		d.Write(openBraceNewlineBytes)
+		d.openBrace()
 		d.depth++
 		if (d.cs.MaxDepth != 0) && (d.depth > d.cs.MaxDepth) {
-			d.indent()
-			d.Write(maxNewlineBytes)
+			d.maxDepthReached()
 		} else {
 			vt := v.Type()
-			numFields := v.NumField()
-			for i := 0; i < numFields; i++ {
+			fields := d.cs.visibleFields(v, d.fieldPath, true)
+			for n, i := range fields {
+				if outputFull(d.w) {
+					break
+				}
 				d.indent()
 				vtf := vt.Field(i)
-				d.Write([]byte(vtf.Name))
+				tag := parseFieldTag(vtf)
+				d.Write([]byte(tag.displayName(vtf)))
 				d.Write(colonSpaceBytes)
-				d.ignoreNextIndent = true
-				d.dump(d.unpackValue(v.Field(i)))
-				if i < (numFields - 1) {
-					d.Write(commaNewlineBytes)
-				} else {
-					d.Write(newlineBytes)
+				d.fieldPath = append(d.fieldPath, vtf.Name)
+				fv := d.unpackValue(v.Field(i))
+				switch {
+				case d.cs.redactField(vtf, d.fieldPath, fv):
+					d.dumpRedacted(fv)
+				case tag.lenOnly && hasLen(fv.Kind()):
+					d.dumpLenOnly(fv)
+				default:
+					savedHex := d.hexInts
+					d.hexInts = d.hexInts || tag.hex
+					d.forceMethods = tag.str
+					d.ignoreNextIndent = true
+					d.dump(fv)
+					d.hexInts = savedHex
+					d.forceMethods = false
 				}
+				d.fieldPath = d.fieldPath[:len(d.fieldPath)-1]
+				d.endElement(n == len(fields)-1)
 			}
 		}
 		d.depth--
@@ -431,10 +674,24 @@ func (d *dumpState) dump(v reflect.Value) {
 		d.Write(closeBraceBytes)
 
 	case reflect.Uintptr:
+		d.setColor(d.cs.theme().Pointer)
 		printHexPtr(d.w, uintptr(v.Uint()))
+		d.resetColor(d.cs.theme().Pointer)
 
-	case reflect.UnsafePointer, reflect.Chan, reflect.Func:
+	case reflect.UnsafePointer:
+		d.setColor(d.cs.theme().Pointer)
 		printHexPtr(d.w, v.Pointer())
+		d.resetColor(d.cs.theme().Pointer)
+
+	case reflect.Chan:
+		d.setColor(d.cs.theme().Pointer)
+		printHexPtr(d.w, v.Pointer())
+		d.resetColor(d.cs.theme().Pointer)
+
+	case reflect.Func:
+		d.setColor(d.cs.theme().Pointer)
+		printFunc(d.w, v)
+		d.resetColor(d.cs.theme().Pointer)
 
 	// There were not any other types at the time this code was written, but
 	// fall back to letting the default fmt package handle it in case any new
@@ -446,23 +703,35 @@ func (d *dumpState) dump(v reflect.Value) {
 		} else {
 			msg = fmt.Sprintf("%v", v.String())
 		}
-		fmt.Fprintf(d.w, msg)
+		fmt.Fprint(d.w, msg)
 	}
 }
 
 // fdump is a helper function to consolidate the logic from the various public
 // methods which take varying writers and config states.
 func fdump(cs *ConfigState, w io.Writer, a ...interface{}) {
+	w = cs.newLimitWriter(w, truncatedLineBytes)
+	if cs.Format != FormatSpew {
+		fdumpStructured(cs, w, a...)
+		return
+	}
+	var refs, labels map[ptrKey]int
+	if cs.ShowSharing {
+		refs, labels = scanSharing(cs, a)
+	}
 	for _, arg := range a {
+		if outputFull(w) {
+			break
+		}
+		d := dumpState{w: w, cs: cs, refs: refs, labels: labels}
 		if arg == nil {
-			write(w, interfaceBytes)
-			write(w, spaceBytes)
-			write(w, nilAngleBytes)
-			write(w, newlineBytes)
+			d.writeType(0, interfaceType)
+			d.Write(spaceBytes)
+			d.writeNil()
+			d.Write(newlineBytes)
 			continue
 		}
 
-		d := dumpState{w: w, cs: cs}
 		d.pointers = make(map[uintptr]int)
 		d.dump(reflect.ValueOf(arg))
 		d.Write(newlineBytes)
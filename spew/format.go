@@ -37,6 +37,10 @@ type formatState struct {
 	depth          int
 	pointers       map[uintptr]int
 	ignoreNextType bool
+	fieldPath      []string
+	hexInts        bool
+	forceMethods   bool
+	snapshots      map[reflect.Type]bool
 	cs             *ConfigState
 }
 
@@ -157,7 +161,7 @@ func (f *formatState) formatPtr(v reflect.Value) {
 	if showTypes && !f.ignoreNextType {
 		f.fs.Write(openParenBytes)
 		f.fs.Write(bytes.Repeat(asteriskBytes, indirects))
-		f.fs.Write([]byte(ve.Type().String()))
+		f.fs.Write([]byte(f.cs.typeName(ve.Type())))
 		f.fs.Write(closeParenBytes)
 	} else {
 		if nilFound || cycleFound {
@@ -194,11 +198,42 @@ func (f *formatState) formatPtr(v reflect.Value) {
 	}
 }
 
+// printRedacted displays the redaction marker in place of a redacted value,
+// preceded by its type when the show types flag is set.
+func (f *formatState) printRedacted(v reflect.Value) {
+	if f.fs.Flag('#') {
+		f.fs.Write(openParenBytes)
+		f.fs.Write([]byte(f.cs.typeName(v.Type())))
+		f.fs.Write(closeParenBytes)
+	}
+	printRedacted(f.fs, v)
+}
+
+// printLenOnly displays the length of a value tagged with the len option in
+// place of its contents, preceded by its type when the show types flag is
+// set.
+func (f *formatState) printLenOnly(v reflect.Value) {
+	if f.fs.Flag('#') {
+		f.fs.Write(openParenBytes)
+		f.fs.Write([]byte(f.cs.typeName(v.Type())))
+		f.fs.Write(closeParenBytes)
+	}
+	f.fs.Write(openAngleBytes)
+	f.fs.Write(lenEqualsBytes)
+	printInt(f.fs, int64(v.Len()), 10)
+	f.fs.Write(closeAngleBytes)
+}
+
 // format is the main workhorse for providing the Formatter interface.  It
 // uses the passed reflect value to figure out what kind of object we are
 // dealing with and formats it appropriately.  It is a recursive function,
 // however circular data structures are detected and handled properly.
 func (f *formatState) format(v reflect.Value) {
+	// Stop once the MaxOutputBytes limit has been reached.
+	if outputFull(f.fs) {
+		return
+	}
+
 	// Handle invalid reflect values immediately.
 	kind := v.Kind()
 	if kind == reflect.Invalid {
@@ -206,6 +241,30 @@ func (f *formatState) format(v reflect.Value) {
 		return
 	}
 
+	// Display a consistent copy of values which implement Snapshotter.
+	if snap, done, ok := f.cs.snapshot(v, f.snapshots); ok {
+		defer done()
+		v = snap
+		if kind = v.Kind(); kind == reflect.Invalid {
+			f.fs.Write(nilAngleBytes)
+			return
+		}
+	}
+
+	// Use a registered formatter or TraceDump method in place of the
+	// generic display when one exists.
+	if custom := f.cs.customFormatter(v); custom != nil {
+		if !f.ignoreNextType && f.fs.Flag('#') {
+			f.fs.Write(openParenBytes)
+			f.fs.Write([]byte(f.cs.typeName(v.Type())))
+			f.fs.Write(closeParenBytes)
+		}
+		f.ignoreNextType = false
+		f.forceMethods = false
+		custom(f.fs)
+		return
+	}
+
 	// Handle pointers specially.
 	if kind == reflect.Ptr {
 		f.formatPtr(v)
@@ -215,14 +274,17 @@ func (f *formatState) format(v reflect.Value) {
 	// Print type information unless already handled elsewhere.
 	if !f.ignoreNextType && f.fs.Flag('#') {
 		f.fs.Write(openParenBytes)
-		f.fs.Write([]byte(v.Type().String()))
+		f.fs.Write([]byte(f.cs.typeName(v.Type())))
 		f.fs.Write(closeParenBytes)
 	}
 	f.ignoreNextType = false
 
 	// Call Stringer/error interfaces if they exist and the handle methods
-	// flag is enabled.
-	if !f.cs.DisableMethods {
+	// flag is enabled or the field being formatted is tagged with the string
+	// option.
+	forceMethods := f.forceMethods
+	f.forceMethods = false
+	if !f.cs.DisableMethods || forceMethods {
 		if (kind != reflect.Invalid) && (kind != reflect.Interface) {
 			if handled := handleMethods(f.cs, f.fs, v); handled {
 				return
@@ -239,10 +301,18 @@ func (f *formatState) format(v reflect.Value) {
 		printBool(f.fs, v.Bool())
 
 	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
-		printInt(f.fs, v.Int(), 10)
+		if f.hexInts {
+			printHexInt(f.fs, v.Int())
+		} else {
+			printInt(f.fs, v.Int(), 10)
+		}
 
 	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
-		printUint(f.fs, v.Uint(), 10)
+		if f.hexInts {
+			printHexUint(f.fs, v.Uint())
+		} else {
+			printUint(f.fs, v.Uint(), 10)
+		}
 
 	case reflect.Float32:
 		printFloat(f.fs, v.Float(), 32)
@@ -270,19 +340,28 @@ func (f *formatState) format(v reflect.Value) {
 			f.fs.Write(maxShortBytes)
 		} else {
 			numEntries := v.Len()
-			for i := 0; i < numEntries; i++ {
+			numShown := f.cs.limitElements(numEntries)
+			for i := 0; i < numShown && !outputFull(f.fs); i++ {
 				if i > 0 {
 					f.fs.Write(spaceBytes)
 				}
 				f.ignoreNextType = true
 				f.format(f.unpackValue(v.Index(i)))
 			}
+			if numShown < numEntries {
+				f.fs.Write(spaceBytes)
+				printMore(f.fs, numEntries-numShown, false)
+			}
 		}
 		f.depth--
 		f.fs.Write(closeBracketBytes)
 
 	case reflect.String:
-		f.fs.Write([]byte(v.String()))
+		str, more := f.cs.limitString(v.String())
+		f.fs.Write([]byte(str))
+		if more > 0 {
+			printMore(f.fs, more, true)
+		}
 
 	case reflect.Interface:
 		// The only time we should get here is for nil interfaces due to
@@ -311,38 +390,69 @@ func (f *formatState) format(v reflect.Value) {
 			if f.cs.SortKeys {
 				sortValues(keys, f.cs)
 			}
-			for i, key := range keys {
+			numShown := f.cs.limitElements(len(keys))
+			for i, key := range keys[:numShown] {
+				if outputFull(f.fs) {
+					break
+				}
 				if i > 0 {
 					f.fs.Write(spaceBytes)
 				}
 				f.ignoreNextType = true
-				f.format(f.unpackValue(key))
+				key = f.unpackValue(key)
+				f.format(key)
 				f.fs.Write(colonBytes)
-				f.ignoreNextType = true
-				f.format(f.unpackValue(v.MapIndex(key)))
+				if mv := v.MapIndex(key); f.cs.redactKey(key, f.fieldPath, mv) {
+					f.printRedacted(mv)
+				} else {
+					f.ignoreNextType = true
+					f.format(f.unpackValue(mv))
+				}
+			}
+			if numShown < len(keys) {
+				f.fs.Write(spaceBytes)
+				printMore(f.fs, len(keys)-numShown, false)
 			}
 		}
 		f.depth--
 		f.fs.Write(closeMapBytes)
 
 	case reflect.Struct:
-		numFields := v.NumField()
 		f.fs.Write(openBraceBytes)
 		f.depth++
 		if (f.cs.MaxDepth != 0) && (f.depth > f.cs.MaxDepth) {
 			f.fs.Write(maxShortBytes)
 		} else {
 			vt := v.Type()
-			for i := 0; i < numFields; i++ {
-				if i > 0 {
+			for n, i := range f.cs.visibleFields(v, f.fieldPath, true) {
+				if outputFull(f.fs) {
+					break
+				}
+				if n > 0 {
 					f.fs.Write(spaceBytes)
 				}
 				vtf := vt.Field(i)
+				tag := parseFieldTag(vtf)
 				if f.fs.Flag('+') || f.fs.Flag('#') {
-					f.fs.Write([]byte(vtf.Name))
+					f.fs.Write([]byte(tag.displayName(vtf)))
 					f.fs.Write(colonBytes)
 				}
-				f.format(f.unpackValue(v.Field(i)))
+				f.fieldPath = append(f.fieldPath, vtf.Name)
+				fv := f.unpackValue(v.Field(i))
+				switch {
+				case f.cs.redactField(vtf, f.fieldPath, fv):
+					f.printRedacted(fv)
+				case tag.lenOnly && hasLen(fv.Kind()):
+					f.printLenOnly(fv)
+				default:
+					savedHex := f.hexInts
+					f.hexInts = f.hexInts || tag.hex
+					f.forceMethods = tag.str
+					f.format(fv)
+					f.hexInts = savedHex
+					f.forceMethods = false
+				}
+				f.fieldPath = f.fieldPath[:len(f.fieldPath)-1]
 			}
 		}
 		f.depth--
@@ -351,9 +461,15 @@ func (f *formatState) format(v reflect.Value) {
 	case reflect.Uintptr:
 		printHexPtr(f.fs, uintptr(v.Uint()))
 
-	case reflect.UnsafePointer, reflect.Chan, reflect.Func:
+	case reflect.UnsafePointer:
 		printHexPtr(f.fs, v.Pointer())
 
+	case reflect.Chan:
+		printHexPtr(f.fs, v.Pointer())
+
+	case reflect.Func:
+		printFunc(f.fs, v)
+
 	// There were not any other types at the time this code was written, but
 	// fall back to letting the default fmt package handle it if any get added.
 	default:
@@ -369,12 +485,16 @@ func (f *formatState) format(v reflect.Value) {
 // Format satisfies the fmt.Formatter interface. See NewFormatter for usage
 // details.
 func (f *formatState) Format(fs fmt.State, verb rune) {
+	if f.cs.MaxOutputBytes > 0 {
+		fs = limitState{fs, f.cs.newLimitWriter(fs, truncatedBytes)}
+	}
 	f.fs = fs
 
-	// Use standard formatting for verbs that are not v.
+	// Use standard formatting for verbs that are not v, on a copy with
+	// the redacted values replaced so they are not revealed.
 	if verb != 'v' {
 		format := f.constructOrigFormat(verb)
-		fmt.Fprintf(fs, format, f.value)
+		fmt.Fprintf(fs, format, f.cs.Redacted(f.value))
 		return
 	}
 
@@ -394,6 +514,7 @@ func (f *formatState) Format(fs fmt.State, verb rune) {
 func newFormatter(cs *ConfigState, v interface{}) fmt.Formatter {
 	fs := &formatState{value: v, cs: cs}
 	fs.pointers = make(map[uintptr]int)
+	fs.snapshots = make(map[reflect.Type]bool)
 	return fs
 }
 
@@ -406,7 +527,8 @@ types similar to the standard %v format specifier.
 The custom formatter only responds to the %v (most compact), %+v (adds pointer
 addresses), %#v (adds types), or %#+v (adds types and pointer addresses) verb
 combinations.  Any other verbs such as %x and %q will be sent to the the
-standard fmt package for formatting.  In addition, the custom formatter ignores
+standard fmt package for formatting, with redacted values replaced as by
+ConfigState.Redacted.  In addition, the custom formatter ignores
 the width and precision arguments (however they will still work on the format
 specifiers not handled by the custom formatter).
 
@@ -0,0 +1,443 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ptrKey identifies a pointer by both its address and its type since a
+// pointer to a struct and a pointer to its first field share an address.
+type ptrKey struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+// goSyntaxState contains information about the state of a Go syntax dump
+// operation.
+type goSyntaxState struct {
+	w         io.Writer
+	depth     int
+	refs      map[ptrKey]int
+	order     []reflect.Value
+	helpers   map[ptrKey]string
+	fieldPath []string
+	cs        *ConfigState
+}
+
+// newline writes a newline followed by indentation for the current depth.
+func (g *goSyntaxState) newline() {
+	write(g.w, newlineBytes)
+	write(g.w, bytes.Repeat([]byte(g.cs.Indent), g.depth))
+}
+
+// scan walks the value the same way lit will and counts how many times each
+// pointer is reached.  Pointers that are reached more than once are shared
+// (or circular) and need a helper variable to be expressed in Go syntax.
+func (g *goSyntaxState) scan(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		k := ptrKey{v.Pointer(), v.Type()}
+		g.refs[k]++
+		if g.refs[k] > 1 {
+			return
+		}
+		g.order = append(g.order, v)
+		g.scan(v.Elem())
+
+	case reflect.Interface:
+		if !v.IsNil() {
+			g.scan(v.Elem())
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			g.scan(v.Index(i))
+		}
+
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			g.scan(key)
+			if mv := v.MapIndex(key); !g.cs.redactKey(key, g.fieldPath, mv) {
+				g.scan(mv)
+			}
+		}
+
+	case reflect.Struct:
+		vt := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			vtf := vt.Field(i)
+			g.fieldPath = append(g.fieldPath, vtf.Name)
+			if vtf.PkgPath == "" && !g.cs.redactField(vtf, g.fieldPath, v.Field(i)) {
+				g.scan(v.Field(i))
+			}
+			g.fieldPath = g.fieldPath[:len(g.fieldPath)-1]
+		}
+	}
+}
+
+// canTakeAddr returns whether a composite literal of the passed kind may
+// have its address taken directly with the & operator.
+func canTakeAddr(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+		return true
+	}
+	return false
+}
+
+// conversionType returns the passed type name as written in a conversion.
+// Pointer, channel and function types must be parenthesized, since
+// *T(x), chan T(x) and func()(x) parse as something else.
+func conversionType(ts string) string {
+	for _, prefix := range []string{"*", "<-", "chan ", "func("} {
+		if strings.HasPrefix(ts, prefix) {
+			return "(" + ts + ")"
+		}
+	}
+	return ts
+}
+
+// writeTyped writes a literal, wrapped in a conversion to the value's type
+// when the type cannot be inferred from context.
+func (g *goSyntaxState) writeTyped(v reflect.Value, lit string, needType bool, defaultType string) {
+	ts := v.Type().String()
+	if needType && ts != defaultType {
+		write(g.w, []byte(conversionType(ts)))
+		write(g.w, openParenBytes)
+		write(g.w, []byte(lit))
+		write(g.w, closeParenBytes)
+		return
+	}
+	write(g.w, []byte(lit))
+}
+
+// floatLit returns the Go source representation of a float.
+func floatLit(f float64, bits int) string {
+	switch {
+	case math.IsNaN(f):
+		return "math.NaN()"
+	case math.IsInf(f, 1):
+		return "math.Inf(1)"
+	case math.IsInf(f, -1):
+		return "math.Inf(-1)"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, bits)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+	return s
+}
+
+// lit writes the passed value as a Go expression.  needType indicates the
+// static type of the surrounding context does not determine the type of the
+// value, such as when the value is held in an interface.
+func (g *goSyntaxState) lit(v reflect.Value, needType bool) {
+	kind := v.Kind()
+	if kind == reflect.Invalid {
+		write(g.w, nilBytes)
+		return
+	}
+
+	switch kind {
+	case reflect.Bool:
+		g.writeTyped(v, strconv.FormatBool(v.Bool()), needType, "bool")
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		g.writeTyped(v, strconv.FormatInt(v.Int(), 10), needType, "int")
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		g.writeTyped(v, strconv.FormatUint(v.Uint(), 10), needType, "")
+
+	case reflect.Uintptr:
+		g.writeTyped(v, "0x"+strconv.FormatUint(v.Uint(), 16), true, "")
+
+	case reflect.Float32:
+		g.writeTyped(v, floatLit(v.Float(), 32), needType, "")
+
+	case reflect.Float64:
+		g.writeTyped(v, floatLit(v.Float(), 64), needType, "float64")
+
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		bits := 64
+		if kind == reflect.Complex64 {
+			bits = 32
+		}
+		s := "complex(" + floatLit(real(c), bits) + ", " + floatLit(imag(c), bits) + ")"
+		g.writeTyped(v, s, needType, "complex128")
+
+	case reflect.String:
+		g.writeTyped(v, strconv.Quote(v.String()), needType, "string")
+
+	case reflect.Interface:
+		if v.IsNil() {
+			write(g.w, nilBytes)
+			return
+		}
+		g.lit(v.Elem(), true)
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			g.writeTyped(v, "nil", needType, "")
+			return
+		}
+		if name, ok := g.helpers[ptrKey{v.Pointer(), v.Type()}]; ok {
+			write(g.w, []byte(name))
+			return
+		}
+		// &[]int(nil) is not valid Go, so pointers to nil slices and
+		// maps are written as new([]int).
+		if elem := v.Elem(); (elem.Kind() == reflect.Slice || elem.Kind() == reflect.Map) && elem.IsNil() {
+			write(g.w, []byte("new("+elem.Type().String()+")"))
+			return
+		}
+		write(g.w, ampersandBytes)
+		g.lit(v.Elem(), true)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			g.writeTyped(v, "nil", needType, "")
+			return
+		}
+		fallthrough
+
+	case reflect.Array:
+		write(g.w, []byte(v.Type().String()))
+		write(g.w, openBraceBytes)
+		numEntries := v.Len()
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			g.byteElems(v)
+		} else if numEntries > 0 {
+			g.depth++
+			for i := 0; i < numEntries; i++ {
+				g.newline()
+				g.lit(v.Index(i), false)
+				write(g.w, commaBytes)
+			}
+			g.depth--
+			g.newline()
+		}
+		write(g.w, closeBraceBytes)
+
+	case reflect.Map:
+		if v.IsNil() {
+			g.writeTyped(v, "nil", needType, "")
+			return
+		}
+		write(g.w, []byte(v.Type().String()))
+		write(g.w, openBraceBytes)
+		keys := v.MapKeys()
+		if len(keys) > 0 {
+			sortValues(keys, g.cs)
+			g.depth++
+			for _, key := range keys {
+				g.newline()
+				g.lit(key, false)
+				write(g.w, colonSpaceBytes)
+				if mv := v.MapIndex(key); g.cs.redactKey(key, g.fieldPath, mv) {
+					g.redacted(mv)
+				} else {
+					g.lit(mv, false)
+					write(g.w, commaBytes)
+				}
+			}
+			g.depth--
+			g.newline()
+		}
+		write(g.w, closeBraceBytes)
+
+	case reflect.Struct:
+		vt := v.Type()
+		write(g.w, []byte(vt.String()))
+		write(g.w, openBraceBytes)
+		numFields := v.NumField()
+		if numFields > 0 {
+			g.depth++
+			for i := 0; i < numFields; i++ {
+				g.newline()
+				vtf := vt.Field(i)
+				if vtf.PkgPath != "" {
+					// Unexported fields cannot be set in a
+					// composite literal outside of their package.
+					write(g.w, []byte("// "+vtf.Name+": unexported field omitted"))
+					continue
+				}
+				write(g.w, []byte(vtf.Name))
+				write(g.w, colonSpaceBytes)
+				g.fieldPath = append(g.fieldPath, vtf.Name)
+				if fv := v.Field(i); g.cs.redactField(vtf, g.fieldPath, fv) {
+					g.redacted(fv)
+				} else {
+					g.lit(fv, false)
+					write(g.w, commaBytes)
+				}
+				g.fieldPath = g.fieldPath[:len(g.fieldPath)-1]
+			}
+			g.depth--
+			g.newline()
+		}
+		write(g.w, closeBraceBytes)
+
+	// Channels, functions, and unsafe pointers have no literal form so
+	// they are emitted as nil with a comment noting what was dropped.
+	default:
+		g.writeTyped(v, "nil", needType, "")
+		if !v.IsNil() {
+			write(g.w, []byte(" /* "+v.Type().String()+" not representable */"))
+		}
+	}
+}
+
+// redacted writes the zero value of a redacted value's type followed by a
+// comment noting the redaction, so the output still compiles.
+func (g *goSyntaxState) redacted(v reflect.Value) {
+	g.lit(reflect.Zero(v.Type()), false)
+	write(g.w, commaBytes)
+	write(g.w, []byte(" // "))
+	printRedacted(g.w, v)
+}
+
+// byteElems writes the elements of a byte array or slice as hex literals,
+// sixteen to a line.
+func (g *goSyntaxState) byteElems(v reflect.Value) {
+	numEntries := v.Len()
+	if numEntries == 0 {
+		return
+	}
+	g.depth++
+	for i := 0; i < numEntries; i++ {
+		if i%16 == 0 {
+			g.newline()
+		} else {
+			write(g.w, spaceBytes)
+		}
+		b := v.Index(i).Uint()
+		write(g.w, []byte{'0', 'x', hexDigits[b>>4], hexDigits[b&0x0f], ','})
+	}
+	g.depth--
+	g.newline()
+}
+
+// dump writes the passed value as a Go expression.  When the value contains
+// shared or circular pointers, or pointers to values which are not composite
+// literals, the expression is wrapped in a function literal which declares a
+// helper variable for each such pointer.
+func (g *goSyntaxState) dump(v reflect.Value) {
+	g.scan(v)
+	g.helpers = make(map[ptrKey]string)
+	var helpers []reflect.Value
+	for _, p := range g.order {
+		k := ptrKey{p.Pointer(), p.Type()}
+		if g.refs[k] > 1 || !canTakeAddr(p.Elem().Kind()) {
+			helpers = append(helpers, p)
+			g.helpers[k] = "p" + strconv.Itoa(len(helpers))
+		}
+	}
+
+	// The value is written where nothing determines its type, so it
+	// needs a conversion unless its type is the default for its literal.
+	if len(helpers) == 0 {
+		g.lit(v, true)
+		return
+	}
+
+	write(g.w, []byte("func() "+v.Type().String()+" {"))
+	g.depth++
+	for _, p := range helpers {
+		g.newline()
+		name := g.helpers[ptrKey{p.Pointer(), p.Type()}]
+		write(g.w, []byte(name+" := new("+p.Type().Elem().String()+")"))
+	}
+	for _, p := range helpers {
+		g.newline()
+		name := g.helpers[ptrKey{p.Pointer(), p.Type()}]
+		write(g.w, []byte("*"+name+" = "))
+		g.lit(p.Elem(), false)
+	}
+	g.newline()
+	write(g.w, []byte("return "))
+	g.lit(v, false)
+	g.depth--
+	g.newline()
+	write(g.w, []byte("}()"))
+}
+
+// fdumpGo is a helper function to consolidate the logic from the various
+// public methods which take varying writers and config states.
+func fdumpGo(cs *ConfigState, w io.Writer, a ...interface{}) {
+	for _, arg := range a {
+		if arg == nil {
+			write(w, nilBytes)
+			write(w, newlineBytes)
+			continue
+		}
+
+		g := goSyntaxState{w: w, cs: cs}
+		g.refs = make(map[ptrKey]int)
+		g.dump(reflect.ValueOf(arg))
+		write(w, newlineBytes)
+	}
+}
+
+// FdumpGo formats and displays the passed arguments to io.Writer w.  It
+// formats exactly the same as DumpGo.
+func FdumpGo(w io.Writer, a ...interface{}) {
+	fdumpGo(&Config, w, a...)
+}
+
+// SdumpGo returns a string with the passed arguments formatted exactly the
+// same as DumpGo.
+func SdumpGo(a ...interface{}) string {
+	var buf bytes.Buffer
+	fdumpGo(&Config, &buf, a...)
+	return buf.String()
+}
+
+/*
+DumpGo displays the passed parameters to standard out as Go composite literals
+which can be pasted into Go source, such as a test case, and compiled.  It
+provides the following features:
+
+	* Named types are used for all literals and conversions
+	* Pointers to structs, arrays, slices, and maps are written as &T{...}
+	* Shared and circular pointers, and pointers to values which have no
+	  composite literal form, are assigned helper variables inside a
+	  function literal so the result is still a single expression
+	* Unexported fields, which cannot be set outside of their package, are
+	  omitted with a comment noting each omission
+	* Channels, functions, and unsafe pointers are written as nil with a
+	  comment since they have no literal form
+
+The configuration options are controlled by an exported package global,
+spew.Config.  Only the Indent option applies.
+
+See FdumpGo if you would prefer dumping to an arbitrary io.Writer or SdumpGo to
+get the formatted result as a string.
+*/
+func DumpGo(a ...interface{}) {
+	fdumpGo(&Config, os.Stdout, a...)
+}
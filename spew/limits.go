@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+var (
+	moreBytes            = []byte("... ")
+	moreSuffixBytes      = []byte(" more")
+	moreByteSuffixBytes  = []byte(" more byte")
+	moreBytesSuffixBytes = []byte(" more bytes")
+	truncatedBytes       = []byte("... <output truncated>")
+	truncatedLineBytes   = []byte("... <output truncated>\n")
+)
+
+// printMore outputs the marker for n elements, or bytes when isBytes is set,
+// which were not displayed due to a size limit to Writer w.
+func printMore(w io.Writer, n int, isBytes bool) {
+	write(w, moreBytes)
+	write(w, []byte(strconv.Itoa(n)))
+	switch {
+	case isBytes && n == 1:
+		write(w, moreByteSuffixBytes)
+	case isBytes:
+		write(w, moreBytesSuffixBytes)
+	default:
+		write(w, moreSuffixBytes)
+	}
+}
+
+// limitElements returns the number of elements to display out of n according
+// to the MaxElements option.
+func (c *ConfigState) limitElements(n int) int {
+	if c.MaxElements > 0 && n > c.MaxElements {
+		return c.MaxElements
+	}
+	return n
+}
+
+// limitBytes returns the number of bytes to hexdump out of n according to the
+// MaxHexdumpBytes option.
+func (c *ConfigState) limitBytes(n int) int {
+	if c.MaxHexdumpBytes > 0 && n > c.MaxHexdumpBytes {
+		return c.MaxHexdumpBytes
+	}
+	return n
+}
+
+// limitString returns the portion of s to display according to the
+// MaxStringLen option and the number of bytes left out.  The string is never
+// cut in the middle of a UTF-8 encoded rune.
+func (c *ConfigState) limitString(s string) (string, int) {
+	if c.MaxStringLen <= 0 || len(s) <= c.MaxStringLen {
+		return s, 0
+	}
+	n := c.MaxStringLen
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n], len(s) - n
+}
+
+// limitWriter is an io.Writer which passes at most remaining bytes to w,
+// followed by a marker showing the output was truncated.  Once the limit is
+// reached, all further writes are discarded.
+type limitWriter struct {
+	w         io.Writer
+	remaining int
+	marker    []byte
+	truncated bool
+}
+
+// newLimitWriter returns w wrapped in a limitWriter when the MaxOutputBytes
+// option is set, otherwise it returns w unchanged.
+func (c *ConfigState) newLimitWriter(w io.Writer, marker []byte) io.Writer {
+	if c.MaxOutputBytes <= 0 {
+		return w
+	}
+	return &limitWriter{w: w, remaining: c.MaxOutputBytes, marker: marker}
+}
+
+// Write satisfies the io.Writer interface.  It always reports the full length
+// of p as written so callers carry on as if the output were complete.
+func (l *limitWriter) Write(p []byte) (n int, err error) {
+	if l.truncated {
+		return len(p), nil
+	}
+	if len(p) <= l.remaining {
+		l.remaining -= len(p)
+		return write(l.w, p)
+	}
+	if _, err = write(l.w, p[:l.remaining]); err != nil {
+		return 0, err
+	}
+	l.remaining = 0
+	l.truncated = true
+	if _, err = write(l.w, l.marker); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// outputFull reports whether w is limited by a limitWriter which has reached
+// its limit.  Any further output is discarded, so the walk over the value being
+// displayed stops early instead of visiting the rest of it.
+func outputFull(w io.Writer) bool {
+	switch l := w.(type) {
+	case *limitWriter:
+		return l.truncated
+	case limitState:
+		return outputFull(l.w)
+	}
+	return false
+}
+
+// limitState wraps a fmt.State so the output of the custom formatter is
+// limited by a limitWriter.
+type limitState struct {
+	fmt.State
+	w io.Writer
+}
+
+// Write satisfies the io.Writer interface.
+func (l limitState) Write(p []byte) (n int, err error) {
+	return l.w.Write(p)
+}
@@ -0,0 +1,385 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// matchFieldPath reports whether the field path p matches pattern.  Each
+// element of pattern is matched against the corresponding field name with
+// path.Match, except "**" which matches any number of field names.
+func matchFieldPath(pattern, p []string) bool {
+	if len(pattern) == 0 {
+		return len(p) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(p); i++ {
+			if matchFieldPath(pattern[1:], p[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(p) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], p[0]); !ok {
+		return false
+	}
+	return matchFieldPath(pattern[1:], p[1:])
+}
+
+// matchFieldPrefix reports whether the field path p is an ancestor of a path
+// which could match pattern.  It is used to keep the structs leading to an
+// included field.
+func matchFieldPrefix(pattern, p []string) bool {
+	if len(p) == 0 {
+		return true
+	}
+	if len(pattern) == 0 {
+		return false
+	}
+	if pattern[0] == "**" {
+		return true
+	}
+	if ok, _ := path.Match(pattern[0], p[0]); !ok {
+		return false
+	}
+	return matchFieldPrefix(pattern[1:], p[1:])
+}
+
+// splitFieldPattern splits a field path pattern into its elements.  A
+// leading "*" element matches any number of field names, like "**", so
+// "*.password" matches a password field at any depth.
+func splitFieldPattern(pattern string) []string {
+	elems := strings.Split(pattern, ".")
+	if len(elems) > 1 && elems[0] == "*" {
+		elems[0] = "**"
+	}
+	return elems
+}
+
+// fieldVisible reports whether the struct field at the passed field path
+// should be displayed according to the IncludeFields and ExcludeFields
+// options.
+func (c *ConfigState) fieldVisible(p []string) bool {
+	for _, pattern := range c.ExcludeFields {
+		if matchFieldPath(splitFieldPattern(pattern), p) {
+			return false
+		}
+	}
+	if len(c.IncludeFields) == 0 {
+		return true
+	}
+	for _, pattern := range c.IncludeFields {
+		elems := splitFieldPattern(pattern)
+		if matchFieldPrefix(elems, p) {
+			return true
+		}
+		for i := 1; i <= len(p); i++ {
+			if matchFieldPath(elems, p[:i]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// visibleFields returns the indices of the fields of the struct value v
+// which should be displayed according to the IncludeFields and
+// ExcludeFields options and the trace tag of each field.  p is the field
+// path of v itself.  Fields tagged with omitempty are only skipped when
+// omitEmpty is set.
+func (c *ConfigState) visibleFields(v reflect.Value, p []string, omitEmpty bool) []int {
+	vt := v.Type()
+	numFields := v.NumField()
+	fields := make([]int, 0, numFields)
+	for i := 0; i < numFields; i++ {
+		vtf := vt.Field(i)
+		tag := parseFieldTag(vtf)
+		if tag.skip || omitEmpty && tag.omitEmpty && isEmptyValue(v.Field(i)) {
+			continue
+		}
+		if len(c.IncludeFields) == 0 && len(c.ExcludeFields) == 0 ||
+			c.fieldVisible(append(p[:len(p):len(p)], vtf.Name)) {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// PathError records an error selecting a sub-value with Select.
+type PathError struct {
+	Path string // the full path passed to Select
+	Pos  string // the portion of the path which was resolved before the error
+	Err  string // description of the problem
+}
+
+// Error satisfies the error interface.
+func (e *PathError) Error() string {
+	if e.Pos == "" {
+		return fmt.Sprintf("spew: path %q: %s", e.Path, e.Err)
+	}
+	return fmt.Sprintf("spew: path %q: at %q: %s", e.Path, e.Pos, e.Err)
+}
+
+// pathElem is a single parsed element of a path passed to Select.
+type pathElem struct {
+	field string // field name, or empty for an index or key
+	index string // index or key, unquoted if it was quoted
+	raw   string // the element as written in the path
+}
+
+// parsePath splits a path such as `Conn.State.Peers[0]` or `Env["HOME"]`
+// into its elements.
+func parsePath(p string) ([]pathElem, error) {
+	var elems []pathElem
+	s := strings.TrimPrefix(p, ".")
+	for len(s) > 0 {
+		switch s[0] {
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if len(s) > 1 && s[1] == '"' {
+				// Find the closing quote, skipping escaped quotes,
+				// so keys may contain brackets.
+				i := 2
+				for i < len(s) && s[i] != '"' {
+					if s[i] == '\\' {
+						i++
+					}
+					i++
+				}
+				end = -1
+				if i+1 < len(s) && s[i+1] == ']' {
+					end = i + 1
+				}
+			}
+			if end < 0 {
+				return nil, &PathError{Path: p, Err: "missing ']'"}
+			}
+			index := s[1:end]
+			if strings.HasPrefix(index, `"`) {
+				unquoted, err := strconv.Unquote(index)
+				if err != nil {
+					return nil, &PathError{Path: p, Err: "bad quoted key " + index}
+				}
+				index = unquoted
+			}
+			elems = append(elems, pathElem{index: index, raw: s[:end+1]})
+			s = s[end+1:]
+		case '.':
+			s = s[1:]
+			if len(s) == 0 || s[0] == '.' || s[0] == '[' {
+				return nil, &PathError{Path: p, Err: "missing field name after '.'"}
+			}
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			elems = append(elems, pathElem{field: s[:end], raw: s[:end]})
+			s = s[end:]
+		}
+	}
+	return elems, nil
+}
+
+// mapKey converts the textual key from a path into a value usable to index a
+// map with the passed key type.
+func mapKey(s string, kt reflect.Type) (reflect.Value, error) {
+	key := reflect.New(kt).Elem()
+	switch kt.Kind() {
+	case reflect.String:
+		key.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return key, err
+		}
+		key.SetBool(b)
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		i, err := strconv.ParseInt(s, 0, kt.Bits())
+		if err != nil {
+			return key, err
+		}
+		key.SetInt(i)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 0, kt.Bits())
+		if err != nil {
+			return key, err
+		}
+		key.SetUint(u)
+	default:
+		return key, fmt.Errorf("unsupported map key type %s", kt)
+	}
+	return key, nil
+}
+
+// Select returns the sub-value of v at the passed path.  A path is a sequence
+// of field names separated by dots, and of slice or array indices and map keys
+// in square brackets, for example:
+//
+//	Conn.State.Peers[0]
+//	Env["HOME"]
+//	Ports[8080].Name
+//
+// Pointers and interfaces are followed automatically.  Unexported fields are
+// selected using unsafe where available.  An error describing the first
+// element of the path that could not be resolved is returned when the path is
+// malformed or does not exist in v.
+func Select(v interface{}, p string) (interface{}, error) {
+	rv, _, err := selectValue(nil, v, p)
+	if err != nil || !rv.IsValid() {
+		return nil, err
+	}
+	return rv.Interface(), nil
+}
+
+// selectValue is the implementation of Select.  When cs is not nil, it also
+// reports whether any struct field or map entry along the path is redacted
+// under cs.
+func selectValue(cs *ConfigState, v interface{}, p string) (rv reflect.Value, redacted bool, err error) {
+	elems, err := parsePath(p)
+	if err != nil {
+		return rv, false, err
+	}
+
+	var fieldPath []string
+	rv = reflect.ValueOf(v)
+	pos := ""
+	for _, elem := range elems {
+		for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+			if rv.IsNil() {
+				return rv, false, &PathError{Path: p, Pos: pos, Err: "nil " + rv.Type().String()}
+			}
+			rv = rv.Elem()
+		}
+		if !rv.IsValid() {
+			return rv, false, &PathError{Path: p, Pos: pos, Err: "nil value"}
+		}
+
+		switch {
+		case elem.field != "":
+			if rv.Kind() != reflect.Struct {
+				return rv, false, &PathError{Path: p, Pos: pos,
+					Err: fmt.Sprintf("no field %q in non-struct %s", elem.field, rv.Type())}
+			}
+			field, ok := rv.Type().FieldByName(elem.field)
+			if !ok {
+				return rv, false, &PathError{Path: p, Pos: pos,
+					Err: fmt.Sprintf("no field %q in %s", elem.field, rv.Type())}
+			}
+			// Walk the index by hand since FieldByIndex panics on a
+			// field promoted through a nil embedded pointer.
+			for n, i := range field.Index {
+				if n > 0 && rv.Kind() == reflect.Ptr {
+					if rv.IsNil() {
+						return rv, false, &PathError{Path: p, Pos: pos,
+							Err: fmt.Sprintf("nil embedded %s holding field %q", rv.Type(), elem.field)}
+					}
+					rv = rv.Elem()
+				}
+				rv = rv.Field(i)
+			}
+			fieldPath = append(fieldPath, field.Name)
+			if cs != nil && cs.redactField(field, fieldPath, rv) {
+				redacted = true
+			}
+
+		case rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array || rv.Kind() == reflect.String:
+			i, err := strconv.Atoi(elem.index)
+			if err != nil {
+				return rv, false, &PathError{Path: p, Pos: pos,
+					Err: fmt.Sprintf("bad index %q for %s", elem.index, rv.Type())}
+			}
+			if i < 0 || i >= rv.Len() {
+				return rv, false, &PathError{Path: p, Pos: pos,
+					Err: fmt.Sprintf("index %d out of range [0:%d]", i, rv.Len())}
+			}
+			rv = rv.Index(i)
+
+		case rv.Kind() == reflect.Map:
+			key, err := mapKey(elem.index, rv.Type().Key())
+			if err != nil {
+				return rv, false, &PathError{Path: p, Pos: pos,
+					Err: fmt.Sprintf("bad key %q for %s: %v", elem.index, rv.Type(), err)}
+			}
+			mv := rv.MapIndex(key)
+			if !mv.IsValid() {
+				return rv, false, &PathError{Path: p, Pos: pos,
+					Err: fmt.Sprintf("key %q not found", elem.index)}
+			}
+			if cs != nil && cs.redactKey(key, fieldPath, mv) {
+				redacted = true
+			}
+			rv = mv
+
+		default:
+			return rv, false, &PathError{Path: p, Pos: pos,
+				Err: fmt.Sprintf("cannot index %s", rv.Type())}
+		}
+		if pos != "" && elem.field != "" {
+			pos += "."
+		}
+		pos += elem.raw
+	}
+
+	if rv.IsValid() && !rv.CanInterface() {
+		if UnsafeDisabled {
+			return rv, false, &PathError{Path: p, Pos: pos, Err: "cannot access unexported field"}
+		}
+		rv = unsafeReflectValue(rv)
+	}
+	return rv, redacted, nil
+}
+
+// fdumpPath is a helper function to consolidate the logic from the various
+// public methods which take varying writers and config states.  Nothing is
+// written when an error is returned.
+func fdumpPath(cs *ConfigState, w io.Writer, v interface{}, p string) error {
+	rv, redacted, err := selectValue(cs, v, p)
+	if err != nil {
+		return err
+	}
+	if !rv.IsValid() {
+		fdump(cs, w, nil)
+		return nil
+	}
+	if redacted {
+		d := dumpState{w: w, cs: cs}
+		d.dumpRedacted(rv)
+		d.Write(newlineBytes)
+		return nil
+	}
+	fdump(cs, w, rv.Interface())
+	return nil
+}
+
+// FdumpPath selects the sub-value of v at the passed path, as described by
+// Select, and displays it to io.Writer w formatted exactly the same as Dump.
+// The value is redacted if any struct field or map entry along the path is
+// redacted.  An error is returned, and nothing is written, when the path is
+// malformed or does not exist in v.
+func FdumpPath(w io.Writer, v interface{}, p string) error {
+	return fdumpPath(&Config, w, v, p)
+}
@@ -0,0 +1,407 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	redactedBytes    = []byte("<redacted>")
+	redactedLenBytes = []byte("<redacted len=")
+)
+
+// DefaultRedactFields holds field name patterns for values which commonly
+// hold credentials.  It is suitable for use as ConfigState.RedactFields.
+// Patterns match whole words of a name, so Password and api_key are
+// redacted but MaxTokens is not.
+var DefaultRedactFields = []string{
+	"password",
+	"passwords",
+	"passwd",
+	"secret",
+	"secrets",
+	"token",
+	"apikey",
+	"api_key",
+	"credential",
+	"credentials",
+}
+
+// Redactor is the interface implemented by types which decide whether a
+// value should be redacted.  It is consulted for every struct field, and for
+// every map entry with a string key, in addition to the RedactFields patterns
+// and the `trace:"redact"` struct tag.
+type Redactor interface {
+	// Redact reports whether v should be redacted.  path is the
+	// dot-separated field path of v, as used by IncludeFields, and name
+	// is the struct field name or map key under which v was found.
+	Redact(path string, name string, v reflect.Value) bool
+}
+
+// nameWords splits a struct field name or map key into its words.  Words are
+// separated by underscores, hyphens, dots and spaces, and by changes of case
+// as in MaxTokens or APIKey.  The words are returned in lower case.
+func nameWords(name string) []string {
+	var words []string
+	runes := []rune(name)
+	start := 0
+	for i := 0; i <= len(runes); i++ {
+		split := i == len(runes)
+		skip := false
+		if !split {
+			switch r := runes[i]; {
+			case r == '_' || r == '-' || r == '.' || r == ' ':
+				split, skip = true, true
+			case i > start && unicode.IsUpper(r):
+				prev := runes[i-1]
+				split = unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+					unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			}
+		}
+		if !split {
+			continue
+		}
+		if i > start {
+			words = append(words, strings.ToLower(string(runes[start:i])))
+		}
+		start = i
+		if skip {
+			start++
+		}
+	}
+	return words
+}
+
+// redactName reports whether a struct field name or map key matches one of
+// the RedactFields patterns.  Both are split into words by nameWords, and a
+// pattern matches when its words match consecutive words of the name, each
+// using path.Match syntax.  Matching is case-insensitive.
+func (c *ConfigState) redactName(name string) bool {
+	if len(c.RedactFields) == 0 {
+		return false
+	}
+	words := nameWords(name)
+	for _, pattern := range c.RedactFields {
+		if matchWords(nameWords(pattern), words) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchWords reports whether the pattern words match a run of consecutive
+// name words.
+func matchWords(pattern, words []string) bool {
+	if len(pattern) == 0 {
+		return false
+	}
+	for i := 0; i+len(pattern) <= len(words); i++ {
+		matched := true
+		for j, p := range pattern {
+			if ok, _ := path.Match(p, words[i+j]); !ok {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// redactField reports whether the value v of the passed struct field, found at
+// field path p, should be redacted.
+func (c *ConfigState) redactField(field reflect.StructField, p []string, v reflect.Value) bool {
+	if parseFieldTag(field).redact || c.redactName(field.Name) {
+		return true
+	}
+	return c.Redactor != nil && c.Redactor.Redact(strings.Join(p, "."), field.Name, v)
+}
+
+// redactKey reports whether the map value v stored under key, found in the
+// map at field path p, should be redacted.  Only string keys are considered.
+func (c *ConfigState) redactKey(key reflect.Value, p []string, v reflect.Value) bool {
+	if key.Kind() != reflect.String {
+		return false
+	}
+	if c.redactName(key.String()) {
+		return true
+	}
+	return c.Redactor != nil && c.Redactor.Redact(strings.Join(p, "."), key.String(), v)
+}
+
+// redactedLen returns the length reported for a redacted value, or -1 if the
+// value has no length.
+func redactedLen(v reflect.Value) int {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	if hasLen(v.Kind()) {
+		return v.Len()
+	}
+	return -1
+}
+
+// printRedacted outputs the marker for a redacted value to Writer w.  The
+// length of the value is included when it has one.
+func printRedacted(w io.Writer, v reflect.Value) {
+	n := redactedLen(v)
+	if n < 0 {
+		write(w, redactedBytes)
+		return
+	}
+	write(w, redactedLenBytes)
+	write(w, []byte(strconv.Itoa(n)))
+	write(w, closeAngleBytes)
+}
+
+// MayRedact reports whether values of type t may contain struct fields or map
+// entries which would be redacted.  Only the static type is considered, so
+// values held in interfaces, such as the Err field of an os.PathError, are
+// not.
+func (c *ConfigState) MayRedact(t reflect.Type) bool {
+	return c.mayRedact(t, make(map[reflect.Type]bool))
+}
+
+// mayRedact is the recursive implementation of MayRedact.  visited guards
+// against recursive types.
+func (c *ConfigState) mayRedact(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if t == nil || visited[t] {
+		return false
+	}
+	visited[t] = true
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return c.mayRedact(t.Elem(), visited)
+
+	case reflect.Map:
+		if t.Key().Kind() == reflect.String && (len(c.RedactFields) > 0 || c.Redactor != nil) {
+			return true
+		}
+		return c.mayRedact(t.Elem(), visited)
+
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if c.Redactor != nil || parseFieldTag(field).redact || c.redactName(field.Name) {
+				return true
+			}
+			if c.mayRedact(field.Type, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redactedValue is stored in place of a redacted value held in an interface
+// by Redacted.  It is displayed as the redaction marker by every verb.
+type redactedValue struct {
+	n int
+}
+
+// Format satisfies the fmt.Formatter interface.
+func (r redactedValue) Format(fs fmt.State, verb rune) {
+	if r.n < 0 {
+		fs.Write(redactedBytes)
+		return
+	}
+	fs.Write(redactedLenBytes)
+	fs.Write([]byte(strconv.Itoa(r.n)))
+	fs.Write(closeAngleBytes)
+}
+
+// redactedValueType is the reflect.Type of redactedValue.
+var redactedValueType = reflect.TypeOf(redactedValue{})
+
+/*
+Redacted returns a copy of v in which the values of the struct fields and map
+entries redacted by c are replaced, so v can be formatted by the fmt package
+with any verb without revealing them.  Redacted strings are replaced by the
+redaction marker, such as <redacted len=7>, as are redacted values held in
+interfaces.  Other redacted values are replaced by the zero value of their
+type.  Everything else is left as it was, so its String and Error methods, nil
+slices and the like are displayed by fmt exactly as for v.  Only the parts of v
+which may hold redacted values are copied, and v itself is returned when there
+are none.
+
+Copying unexported fields relies on the unsafe package.  When it is not
+available, a value which may need redacting is returned as a custom formatter
+of c instead, which redacts for the %v verb and hides the value for all
+others.
+*/
+func (c *ConfigState) Redacted(v interface{}) interface{} {
+	if v == nil || !c.MayRedact(reflect.TypeOf(v)) {
+		return v
+	}
+	if UnsafeDisabled {
+		return redactedFormatter{newFormatter(c, v)}
+	}
+	r := redactState{cs: c, mayRedact: make(map[reflect.Type]bool),
+		copies: make(map[uintptr]reflect.Value)}
+	return r.copy(reflect.ValueOf(v)).Interface()
+}
+
+// redactedFormatter formats a value which may need redacting with a custom
+// formatter for the %v verb, and as the redaction marker for all others.
+type redactedFormatter struct {
+	f fmt.Formatter
+}
+
+// Format satisfies the fmt.Formatter interface.
+func (r redactedFormatter) Format(fs fmt.State, verb rune) {
+	if verb == 'v' {
+		r.f.Format(fs, verb)
+		return
+	}
+	fs.Write(redactedBytes)
+}
+
+// redactState contains information about the state of a Redacted copy.
+type redactState struct {
+	cs        *ConfigState
+	mayRedact map[reflect.Type]bool
+	copies    map[uintptr]reflect.Value
+	fieldPath []string
+}
+
+// needsCopy reports whether values of type t may hold redacted values and
+// must be copied.  Results are cached since copying asks for each element.
+func (r *redactState) needsCopy(t reflect.Type) bool {
+	may, ok := r.mayRedact[t]
+	if !ok {
+		may = r.cs.MayRedact(t)
+		r.mayRedact[t] = may
+	}
+	return may
+}
+
+// set stores src in dst, bypassing the restrictions on unexported fields.
+func set(dst, src reflect.Value) {
+	unsafeReflectValue(dst).Set(unsafeReflectValue(src))
+}
+
+// marker returns the value stored in place of the redacted value v.
+func marker(v reflect.Value) reflect.Value {
+	t := v.Type()
+	switch {
+	case t.Kind() == reflect.String:
+		var buf bytes.Buffer
+		printRedacted(&buf, v)
+		return reflect.ValueOf(buf.String()).Convert(t)
+
+	case t.Kind() == reflect.Interface && redactedValueType.Implements(t):
+		m := reflect.New(t).Elem()
+		m.Set(reflect.ValueOf(redactedValue{redactedLen(v)}))
+		return m
+	}
+	return reflect.Zero(t)
+}
+
+// copy returns a copy of v with its redacted values replaced.  Pointers are
+// copied once each, so shared and circular data keeps its shape.
+func (r *redactState) copy(v reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+	t := v.Type()
+	if kind := t.Kind(); kind != reflect.Interface && !r.needsCopy(t) {
+		return v
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if v.IsNil() || !r.needsCopy(v.Elem().Type()) {
+			return v
+		}
+		c := reflect.New(t).Elem()
+		set(c, r.copy(v.Elem()))
+		return c
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		if c, ok := r.copies[v.Pointer()]; ok && c.Type() == t {
+			return c
+		}
+		c := reflect.New(t.Elem())
+		r.copies[v.Pointer()] = c
+		set(c.Elem(), r.copy(v.Elem()))
+		return c
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(t, v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			set(c.Index(i), r.copy(v.Index(i)))
+		}
+		return c
+
+	case reflect.Array:
+		c := reflect.New(t).Elem()
+		for i := 0; i < v.Len(); i++ {
+			set(c.Index(i), r.copy(v.Index(i)))
+		}
+		return c
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMap(t)
+		for _, key := range v.MapKeys() {
+			mv := v.MapIndex(key)
+			if r.cs.redactKey(key, r.fieldPath, mv) {
+				mv = marker(mv)
+			} else {
+				mv = r.copy(mv)
+			}
+			c.SetMapIndex(unsafeReflectValue(key), unsafeReflectValue(mv))
+		}
+		return c
+
+	case reflect.Struct:
+		c := reflect.New(t).Elem()
+		set(c, v)
+		for i := 0; i < v.NumField(); i++ {
+			field := t.Field(i)
+			fv := v.Field(i)
+			r.fieldPath = append(r.fieldPath, field.Name)
+			if r.cs.redactField(field, r.fieldPath, fv) {
+				set(c.Field(i), marker(fv))
+			} else {
+				set(c.Field(i), r.copy(fv))
+			}
+			r.fieldPath = r.fieldPath[:len(r.fieldPath)-1]
+		}
+		return c
+	}
+	return v
+}
@@ -0,0 +1,350 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Renderer is a set of flags selecting the built-in renderers used to display
+// common standard library types in a readable form instead of their internal
+// fields.  The renderers are used regardless of the DisableMethods option.
+type Renderer uint
+
+const (
+	// RenderTime displays time.Time values in RFC 3339 format and
+	// time.Duration values like 1h2m3s.
+	RenderTime Renderer = 1 << iota
+
+	// RenderBigInt displays big.Int, big.Float and big.Rat values as
+	// numbers.
+	RenderBigInt
+
+	// RenderNetIP displays net.IP, net.IPNet and net.HardwareAddr values in
+	// their usual textual forms.
+	RenderNetIP
+
+	// RenderURL displays url.URL values as URLs with any password
+	// redacted.
+	RenderURL
+
+	// RenderMutex displays sync.Mutex and sync.RWMutex values as their lock
+	// state.
+	RenderMutex
+
+	// RenderReflectValue displays reflect.Value values as the value they
+	// hold.
+	RenderReflectValue
+
+	// RenderSync displays sync.WaitGroup counters, whether a sync.Once
+	// has run, and the values held by the sync/atomic types.
+	RenderSync
+
+	// RenderAll enables all of the built-in renderers.
+	RenderAll = RenderTime | RenderBigInt | RenderNetIP | RenderURL |
+		RenderMutex | RenderReflectValue | RenderSync
+)
+
+// renderer is a built-in renderer for a single type.
+type renderer struct {
+	flag   Renderer
+	render func(c *ConfigState, w io.Writer, v reflect.Value)
+}
+
+// renderers holds the built-in renderers keyed by the type they display.
+var renderers = map[reflect.Type]renderer{
+	typeOf((*time.Time)(nil)):        {RenderTime, renderTime},
+	typeOf((*time.Duration)(nil)):    {RenderTime, renderStringer},
+	typeOf((*big.Int)(nil)):          {RenderBigInt, renderBigInt},
+	typeOf((*big.Float)(nil)):        {RenderBigInt, renderBigFloat},
+	typeOf((*big.Rat)(nil)):          {RenderBigInt, renderBigRat},
+	typeOf((*net.IP)(nil)):           {RenderNetIP, renderStringer},
+	typeOf((*net.IPNet)(nil)):        {RenderNetIP, renderIPNet},
+	typeOf((*net.HardwareAddr)(nil)): {RenderNetIP, renderStringer},
+	typeOf((*url.URL)(nil)):          {RenderURL, renderURL},
+	typeOf((*sync.Mutex)(nil)):       {RenderMutex, renderMutex},
+	typeOf((*sync.RWMutex)(nil)):     {RenderMutex, renderRWMutex},
+	typeOf((*reflect.Value)(nil)):    {RenderReflectValue, renderReflectValue},
+	typeOf((*sync.WaitGroup)(nil)):   {RenderSync, renderWaitGroup},
+	typeOf((*sync.Once)(nil)):        {RenderSync, renderOnce},
+}
+
+// typeOf returns the type pointed to by the passed pointer type.  Taking the
+// type from a nil pointer avoids copying values, such as locks, which must
+// not be copied.
+func typeOf(p interface{}) reflect.Type {
+	return reflect.TypeOf(p).Elem()
+}
+
+// builtinRenderer returns the built-in renderer enabled in c for the type of
+// v, or nil if there is none.
+func (c *ConfigState) builtinRenderer(v reflect.Value) func(c *ConfigState, w io.Writer, v reflect.Value) {
+	if c.Renderers == 0 {
+		return nil
+	}
+	r, ok := renderers[v.Type()]
+	if !ok {
+		// The sync/atomic types are matched by name since most of them
+		// are newer than the oldest supported Go version.
+		if c.Renderers&RenderSync != 0 && v.Kind() == reflect.Struct &&
+			v.Type().PkgPath() == "sync/atomic" {
+			return renderAtomic
+		}
+		return nil
+	}
+	if c.Renderers&r.flag == 0 {
+		return nil
+	}
+	return r.render
+}
+
+// renderStringer displays values whose String method has a value receiver.
+func renderStringer(c *ConfigState, w io.Writer, v reflect.Value) {
+	write(w, []byte(v.Interface().(fmt.Stringer).String()))
+}
+
+// renderTime displays a time.Time in RFC 3339 format with nanoseconds.
+func renderTime(c *ConfigState, w io.Writer, v reflect.Value) {
+	write(w, []byte(v.Interface().(time.Time).Format(time.RFC3339Nano)))
+}
+
+// renderBigInt displays a big.Int in decimal.
+func renderBigInt(c *ConfigState, w io.Writer, v reflect.Value) {
+	x := v.Interface().(big.Int)
+	write(w, []byte(x.String()))
+}
+
+// renderBigFloat displays a big.Float with the smallest number of digits
+// needed to represent it exactly.
+func renderBigFloat(c *ConfigState, w io.Writer, v reflect.Value) {
+	x := v.Interface().(big.Float)
+	write(w, []byte(x.Text('g', -1)))
+}
+
+// renderBigRat displays a big.Rat as a fraction, or as an integer when the
+// denominator is one.
+func renderBigRat(c *ConfigState, w io.Writer, v reflect.Value) {
+	x := v.Interface().(big.Rat)
+	write(w, []byte(x.RatString()))
+}
+
+// renderIPNet displays a net.IPNet in CIDR notation.
+func renderIPNet(c *ConfigState, w io.Writer, v reflect.Value) {
+	x := v.Interface().(net.IPNet)
+	write(w, []byte(x.String()))
+}
+
+// renderURL displays a url.URL with any password replaced by "xxxxx".
+func renderURL(c *ConfigState, w io.Writer, v reflect.Value) {
+	x := v.Interface().(url.URL)
+	write(w, []byte(x.Redacted()))
+}
+
+// Lock state bits and limits from the sync package.
+const (
+	mutexLocked       = 1
+	rwmutexMaxReaders = 1 << 30
+)
+
+// intField returns the value of the integer field with the passed name,
+// searching v and its nested structs depth first.  When the field is itself a
+// struct, such as an atomic.Int32, its first integer field is returned.  The
+// field is loaded atomically when v is addressable, since the sync types are
+// changed concurrently by the goroutines using them.
+func intField(v reflect.Value, name string) (int64, bool) {
+	if v.Kind() != reflect.Struct {
+		return 0, false
+	}
+	vt := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if vt.Field(i).Name == name {
+			return intValue(f)
+		}
+		if n, ok := intField(f, name); ok {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// plainInt returns the value of an integer without atomic operations.
+// Unsigned values are returned with the same bits.
+func plainInt(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return v.Int(), true
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint, reflect.Uintptr:
+		return int64(v.Uint()), true
+	}
+	return 0, false
+}
+
+// intValue returns the value of an integer, or of the first integer field of
+// a struct, loaded atomically when possible.
+func intValue(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return loadInt(v)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if n, ok := intValue(v.Field(i)); ok {
+				return n, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// renderMutex displays the lock state of a sync.Mutex.
+func renderMutex(c *ConfigState, w io.Writer, v reflect.Value) {
+	state, ok := intField(v, "state")
+	switch {
+	case !ok:
+		write(w, []byte("<unknown>"))
+	case state&mutexLocked != 0:
+		write(w, []byte("locked"))
+	default:
+		write(w, []byte("unlocked"))
+	}
+}
+
+// renderRWMutex displays the lock state of a sync.RWMutex, including the
+// number of readers holding it.
+func renderRWMutex(c *ConfigState, w io.Writer, v reflect.Value) {
+	state, ok1 := intField(v, "state")
+	readers, ok2 := intField(v, "readerCount")
+	if !ok1 || !ok2 {
+		write(w, []byte("<unknown>"))
+		return
+	}
+	if readers < 0 {
+		// A writer is waiting for, or holds, the lock.
+		readers += rwmutexMaxReaders
+	}
+	switch {
+	case readers > 0:
+		write(w, []byte("rlocked (readers="+strconv.FormatInt(readers, 10)+")"))
+	case state&mutexLocked != 0:
+		write(w, []byte("locked"))
+	default:
+		write(w, []byte("unlocked"))
+	}
+}
+
+// renderReflectValue displays the value held by a reflect.Value in the style
+// of the %#v verb of the custom formatter.
+func renderReflectValue(c *ConfigState, w io.Writer, v reflect.Value) {
+	rv := v.Interface().(reflect.Value)
+	if !rv.IsValid() {
+		write(w, invalidAngleBytes)
+		return
+	}
+	if !rv.CanInterface() {
+		if UnsafeDisabled {
+			write(w, []byte(rv.String()))
+			return
+		}
+		rv = unsafeReflectValue(rv)
+	}
+	fmt.Fprintf(stdoutWriter{w}, "%#v", newFormatter(c, rv.Interface()))
+}
+
+// renderWaitGroup displays the counter of a sync.WaitGroup, which is held in
+// the high 32 bits of its state.
+func renderWaitGroup(c *ConfigState, w io.Writer, v reflect.Value) {
+	state, ok := intField(v, "state")
+	if !ok {
+		write(w, []byte("<unknown>"))
+		return
+	}
+	write(w, []byte("counter="+strconv.FormatInt(int64(int32(uint64(state)>>32)), 10)))
+}
+
+// renderOnce displays whether the function passed to a sync.Once has run.
+func renderOnce(c *ConfigState, w io.Writer, v reflect.Value) {
+	done, ok := intField(v, "done")
+	switch {
+	case !ok:
+		write(w, []byte("<unknown>"))
+	case done != 0:
+		write(w, []byte("done"))
+	default:
+		write(w, []byte("not done"))
+	}
+}
+
+// renderAtomic displays the value held by one of the sync/atomic types.  The
+// value is loaded atomically when v is addressable.
+func renderAtomic(c *ConfigState, w io.Writer, v reflect.Value) {
+	name := v.Type().Name()
+	f := v.FieldByName("v")
+	if !f.IsValid() {
+		write(w, []byte("<unknown>"))
+		return
+	}
+	switch {
+	case name == "Bool":
+		if n, _ := loadInt(f); n != 0 {
+			write(w, trueBytes)
+		} else {
+			write(w, falseBytes)
+		}
+
+	case name == "Value":
+		x := loadAtomicValue(v)
+		if x == nil {
+			write(w, nilAngleBytes)
+			return
+		}
+		fmt.Fprintf(stdoutWriter{w}, "%#v", newFormatter(c, x))
+
+	case strings.HasPrefix(name, "Pointer["):
+		p, ok := pointerAt(v.Field(0).Type().Elem().Elem(), f)
+		switch {
+		case !ok:
+			printHexPtr(w, loadPointer(f))
+		case p.Pointer() == 0:
+			write(w, nilAngleBytes)
+		default:
+			fmt.Fprintf(stdoutWriter{w}, "%#v", newFormatter(c, p.Interface()))
+		}
+
+	default:
+		n, ok := loadInt(f)
+		switch {
+		case !ok:
+			write(w, []byte("<unknown>"))
+		case f.Kind() == reflect.Int32 || f.Kind() == reflect.Int64:
+			printInt(w, n, 10)
+		case f.Kind() == reflect.Uintptr:
+			printHexPtr(w, uintptr(n))
+		default:
+			printUint(w, uint64(n), 10)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// scanSharing counts the references to each pointer reachable from the
+// passed arguments.  It returns the counts along with an empty map to be
+// filled with the labels of pointers referenced more than once as they are
+// displayed.
+func scanSharing(cs *ConfigState, a []interface{}) (refs, labels map[ptrKey]int) {
+	d := dumpState{cs: cs, refs: make(map[ptrKey]int)}
+	for _, arg := range a {
+		d.scan(reflect.ValueOf(arg))
+	}
+	return d.refs, make(map[ptrKey]int)
+}
+
+// scan walks v the same way dump does, counting the references to each
+// pointer in d.refs.  The values pointed to are only walked once.  Like dump,
+// the walk stops at the MaxDepth option and skips the elements left out due
+// to the MaxElements option, so values which are not displayed are not
+// labeled.  Values which implement Snapshotter are not walked since they may
+// be changing.
+func (d *dumpState) scan(v reflect.Value) {
+	if !v.IsValid() || d.cs.customFormatter(v) != nil {
+		return
+	}
+	if _, ok := d.cs.methodReceiver(v, snapshotterType); ok {
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			d.scan(v.Elem())
+		}
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		k := ptrKey{v.Pointer(), v.Type()}
+		d.refs[k]++
+		if d.refs[k] == 1 {
+			d.scan(v.Elem())
+		}
+
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 || !d.scanDeeper() {
+			return
+		}
+		defer func() { d.depth-- }()
+		for i := 0; i < d.cs.limitElements(v.Len()); i++ {
+			d.scan(v.Index(i))
+		}
+
+	case reflect.Map:
+		if !d.scanDeeper() {
+			return
+		}
+		defer func() { d.depth-- }()
+		keys := v.MapKeys()
+		if d.cs.SortKeys {
+			sortValues(keys, d.cs)
+		}
+		for _, key := range keys[:d.cs.limitElements(len(keys))] {
+			d.scan(key)
+			if mv := v.MapIndex(key); !d.cs.redactKey(key, d.fieldPath, mv) {
+				d.scan(mv)
+			}
+		}
+
+	case reflect.Struct:
+		if !d.scanDeeper() {
+			return
+		}
+		defer func() { d.depth-- }()
+		vt := v.Type()
+		for _, i := range d.cs.visibleFields(v, d.fieldPath, true) {
+			vtf := vt.Field(i)
+			d.fieldPath = append(d.fieldPath, vtf.Name)
+			fv := v.Field(i)
+			if !d.cs.redactField(vtf, d.fieldPath, fv) && !parseFieldTag(vtf).lenOnly {
+				d.scan(fv)
+			}
+			d.fieldPath = d.fieldPath[:len(d.fieldPath)-1]
+		}
+	}
+}
+
+// scanDeeper descends a level into a value being scanned, returning false
+// without doing so when the contents at that level are beyond the MaxDepth
+// option.
+func (d *dumpState) scanDeeper() bool {
+	if d.cs.MaxDepth != 0 && d.depth >= d.cs.MaxDepth {
+		return false
+	}
+	d.depth++
+	return true
+}
+
+// writeLabel displays the label of a shared value, either &N where the value
+// is displayed or *N where it is referenced again.  The label follows the
+// pointer addresses, if any.
+func (d *dumpState) writeLabel(afterAddrs bool, prefix []byte, label int) {
+	if afterAddrs {
+		d.Write(spaceBytes)
+	}
+	d.setColor(d.cs.theme().Pointer)
+	d.Write(prefix)
+	d.Write([]byte(strconv.Itoa(label)))
+	d.resetColor(d.cs.theme().Pointer)
+}
@@ -0,0 +1,683 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DumpFormat specifies the layout used by the Dump family of functions.
+type DumpFormat int
+
+const (
+	// FormatSpew is the default (type) value layout.
+	FormatSpew DumpFormat = iota
+
+	// FormatJSON outputs each value as a JSON document.
+	FormatJSON
+
+	// FormatYAML outputs each value as a YAML document.
+	FormatYAML
+)
+
+var (
+	nullBytes       = []byte("null")
+	yamlDocBytes    = []byte("---\n")
+	dashSpaceBytes  = []byte("- ")
+	emptyListBytes  = []byte("[]")
+	emptyMapBytes   = []byte("{}")
+	yamlCommentText = "# "
+	yamlIndentText  = "  "
+	maxDepthText    = "<max depth reached>"
+	moreKeyText     = "..."
+)
+
+// nodeKind identifies the kind of a node in a structured dump.
+type nodeKind int
+
+const (
+	nodeNull nodeKind = iota
+	nodeBool
+	nodeNumber
+	nodeString
+	nodeList
+	nodeMap
+)
+
+// node is a value in a structured dump.  Scalars hold their text, lists hold
+// elems, and maps hold keys along with the matching elems.  typ holds the
+// type annotation when the AnnotateTypes option is set.
+type node struct {
+	kind  nodeKind
+	text  string
+	keys  []string
+	elems []*node
+	typ   string
+}
+
+// structuredState contains information about the state of a JSON or YAML
+// dump operation.  Values are walked with the same rules as dumpState into a
+// tree of nodes which is then encoded.
+type structuredState struct {
+	depth        int
+	pointers     map[uintptr]int
+	fieldPath    []string
+	hexInts      bool
+	forceMethods bool
+	snapshots    map[reflect.Type]bool
+	cs           *ConfigState
+}
+
+// stringNode returns a string node holding s.
+func stringNode(s string) *node {
+	return &node{kind: nodeString, text: s}
+}
+
+// writerNode returns a string node holding the output of fn.
+func writerNode(fn func(w io.Writer)) *node {
+	var buf bytes.Buffer
+	fn(&buf)
+	return stringNode(buf.String())
+}
+
+// moreText returns the marker for n elements, or bytes when isBytes is set,
+// left out due to a size limit.
+func moreText(n int, isBytes bool) string {
+	var buf bytes.Buffer
+	printMore(&buf, n, isBytes)
+	return buf.String()
+}
+
+// annotate records the type of v on n when the AnnotateTypes option is set.
+func (s *structuredState) annotate(n *node, indirects int, t reflect.Type) *node {
+	if s.cs.AnnotateTypes {
+		n.typ = strings.Repeat("*", indirects) + s.cs.typeName(t)
+	}
+	return n
+}
+
+// unpackValue returns values inside of non-nil interfaces when possible.
+func (s *structuredState) unpackValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// value returns the node for v.  It is a recursive function, however circular
+// data structures are detected and handled properly.
+func (s *structuredState) value(v reflect.Value) *node {
+	if !v.IsValid() {
+		return &node{kind: nodeNull}
+	}
+
+	// Use a consistent copy of values which implement Snapshotter.
+	if snap, done, ok := s.cs.snapshot(v, s.snapshots); ok {
+		defer done()
+		v = snap
+		if !v.IsValid() {
+			return &node{kind: nodeNull}
+		}
+	}
+
+	if custom := s.cs.customFormatter(v); custom != nil {
+		s.forceMethods = false
+		return s.annotate(writerNode(custom), 0, v.Type())
+	}
+
+	if v.Kind() == reflect.Ptr {
+		return s.ptr(v)
+	}
+
+	forceMethods := s.forceMethods
+	s.forceMethods = false
+	if (!s.cs.DisableMethods || forceMethods) && v.Kind() != reflect.Interface {
+		var buf bytes.Buffer
+		if handleMethods(s.cs, &buf, v) {
+			return s.annotate(stringNode(buf.String()), 0, v.Type())
+		}
+	}
+	return s.annotate(s.kindValue(v), 0, v.Type())
+}
+
+// ptr returns the node for the value a pointer points to, following chains
+// of pointers and detecting circular references.
+func (s *structuredState) ptr(v reflect.Value) *node {
+	// Remove pointers at or below the current depth from map used to detect
+	// circular refs.
+	for k, depth := range s.pointers {
+		if depth >= s.depth {
+			delete(s.pointers, k)
+		}
+	}
+
+	indirects := 0
+	ve := v
+	for ve.Kind() == reflect.Ptr {
+		if ve.IsNil() {
+			return s.annotate(&node{kind: nodeNull}, indirects, ve.Type())
+		}
+		indirects++
+		addr := ve.Pointer()
+		if pd, ok := s.pointers[addr]; ok && pd < s.depth {
+			return s.annotate(stringNode(string(circularBytes)), indirects-1, ve.Type())
+		}
+		s.pointers[addr] = s.depth
+
+		ve = ve.Elem()
+		if ve.Kind() == reflect.Interface {
+			if ve.IsNil() {
+				return s.annotate(&node{kind: nodeNull}, indirects, ve.Type())
+			}
+			ve = ve.Elem()
+		}
+	}
+	n := s.value(ve)
+	if n.typ != "" {
+		n.typ = strings.Repeat("*", indirects) + n.typ
+	}
+	return n
+}
+
+// kindValue returns the node for v according to its kind.
+func (s *structuredState) kindValue(v reflect.Value) *node {
+	switch kind := v.Kind(); kind {
+	case reflect.Bool:
+		return &node{kind: nodeBool, text: strconv.FormatBool(v.Bool())}
+
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		if s.hexInts {
+			return writerNode(func(w io.Writer) { printHexInt(w, v.Int()) })
+		}
+		return &node{kind: nodeNumber, text: strconv.FormatInt(v.Int(), 10)}
+
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		if s.hexInts {
+			return writerNode(func(w io.Writer) { printHexUint(w, v.Uint()) })
+		}
+		return &node{kind: nodeNumber, text: strconv.FormatUint(v.Uint(), 10)}
+
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		bits := 64
+		if kind == reflect.Float32 {
+			bits = 32
+		}
+		text := strconv.FormatFloat(f, 'g', -1, bits)
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return stringNode(text)
+		}
+		return &node{kind: nodeNumber, text: text}
+
+	case reflect.Complex64:
+		return writerNode(func(w io.Writer) { printComplex(w, v.Complex(), 32) })
+
+	case reflect.Complex128:
+		return writerNode(func(w io.Writer) { printComplex(w, v.Complex(), 64) })
+
+	case reflect.String:
+		str, more := s.cs.limitString(v.String())
+		if more > 0 {
+			str += moreText(more, true)
+		}
+		return stringNode(str)
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return &node{kind: nodeNull}
+		}
+		return s.list(v)
+
+	case reflect.Array:
+		return s.list(v)
+
+	case reflect.Map:
+		if v.IsNil() {
+			return &node{kind: nodeNull}
+		}
+		return s.mapping(v)
+
+	case reflect.Struct:
+		return s.structure(v)
+
+	case reflect.Interface:
+		// The only time we should get here is for nil interfaces due to
+		// unpackValue calls.
+		return &node{kind: nodeNull}
+
+	case reflect.Chan:
+		if v.IsNil() {
+			return &node{kind: nodeNull}
+		}
+		return writerNode(func(w io.Writer) { printHexPtr(w, v.Pointer()) })
+
+	case reflect.Func:
+		if v.IsNil() {
+			return &node{kind: nodeNull}
+		}
+		return writerNode(func(w io.Writer) { printFunc(w, v) })
+
+	case reflect.Uintptr:
+		return writerNode(func(w io.Writer) { printHexPtr(w, uintptr(v.Uint())) })
+
+	case reflect.UnsafePointer:
+		if v.Pointer() == 0 {
+			return &node{kind: nodeNull}
+		}
+		return writerNode(func(w io.Writer) { printHexPtr(w, v.Pointer()) })
+	}
+	return stringNode(v.String())
+}
+
+// bytesNode returns the node for the contents of a byte array or slice, or
+// nil if v holds other elements.  The contents are output as a string in the
+// format selected by the ByteFormat or ByteArrayFormat option, with hex used
+// for formats which span several lines.
+func (s *structuredState) bytesNode(v reflect.Value) *node {
+	buf, ok := byteContents(v)
+	if !ok {
+		return nil
+	}
+	numBytes := s.cs.limitBytes(len(buf))
+	shown := buf[:numBytes]
+	var str string
+	switch format := s.cs.byteFormat(v.Kind()); {
+	case format == ByteBase64:
+		str = base64.StdEncoding.EncodeToString(shown)
+	case format == ByteString && utf8.Valid(buf):
+		str = string(shown)
+	default:
+		str = hex.EncodeToString(shown)
+	}
+	if numBytes < len(buf) {
+		str += moreText(len(buf)-numBytes, true)
+	}
+	return stringNode(str)
+}
+
+// list returns the node for an array or slice.
+func (s *structuredState) list(v reflect.Value) *node {
+	if n := s.bytesNode(v); n != nil {
+		return n
+	}
+	s.depth++
+	defer func() { s.depth-- }()
+	if s.cs.MaxDepth != 0 && s.depth > s.cs.MaxDepth {
+		return stringNode(maxDepthText)
+	}
+	n := &node{kind: nodeList}
+	numEntries := v.Len()
+	numShown := s.cs.limitElements(numEntries)
+	for i := 0; i < numShown; i++ {
+		n.elems = append(n.elems, s.value(s.unpackValue(v.Index(i))))
+	}
+	if numShown < numEntries {
+		n.elems = append(n.elems, stringNode(moreText(numEntries-numShown, false)))
+	}
+	return n
+}
+
+// mapKey returns the text used for a map key.  Keys other than strings are
+// displayed with the custom formatter when the SpewKeys option is set, and
+// with fmt otherwise.
+func (s *structuredState) mapKey(key reflect.Value) string {
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+	if !key.CanInterface() {
+		if UnsafeDisabled {
+			return key.String()
+		}
+		key = unsafeReflectValue(key)
+	}
+	if s.cs.SpewKeys {
+		return s.cs.Sprintf("%v", key.Interface())
+	}
+	return fmt.Sprintf("%v", key.Interface())
+}
+
+// mapping returns the node for a map.
+func (s *structuredState) mapping(v reflect.Value) *node {
+	s.depth++
+	defer func() { s.depth-- }()
+	if s.cs.MaxDepth != 0 && s.depth > s.cs.MaxDepth {
+		return stringNode(maxDepthText)
+	}
+	n := &node{kind: nodeMap}
+	keys := v.MapKeys()
+	if s.cs.SortKeys {
+		sortValues(keys, s.cs)
+	}
+	numShown := s.cs.limitElements(len(keys))
+	for _, key := range keys[:numShown] {
+		key = s.unpackValue(key)
+		n.keys = append(n.keys, s.mapKey(key))
+		if mv := v.MapIndex(key); s.cs.redactKey(key, s.fieldPath, mv) {
+			n.elems = append(n.elems, writerNode(func(w io.Writer) { printRedacted(w, mv) }))
+		} else {
+			n.elems = append(n.elems, s.value(s.unpackValue(mv)))
+		}
+	}
+	if numShown < len(keys) {
+		n.keys = append(n.keys, moreKeyText)
+		n.elems = append(n.elems, stringNode(strconv.Itoa(len(keys)-numShown)+string(moreSuffixBytes)))
+	}
+	return n
+}
+
+// structure returns the node for a struct.
+func (s *structuredState) structure(v reflect.Value) *node {
+	s.depth++
+	defer func() { s.depth-- }()
+	if s.cs.MaxDepth != 0 && s.depth > s.cs.MaxDepth {
+		return stringNode(maxDepthText)
+	}
+	n := &node{kind: nodeMap}
+	vt := v.Type()
+	for _, i := range s.cs.visibleFields(v, s.fieldPath, true) {
+		vtf := vt.Field(i)
+		tag := parseFieldTag(vtf)
+		n.keys = append(n.keys, tag.displayName(vtf))
+		s.fieldPath = append(s.fieldPath, vtf.Name)
+		fv := s.unpackValue(v.Field(i))
+		switch {
+		case s.cs.redactField(vtf, s.fieldPath, fv):
+			n.elems = append(n.elems, writerNode(func(w io.Writer) { printRedacted(w, fv) }))
+		case tag.lenOnly && hasLen(fv.Kind()):
+			n.elems = append(n.elems, stringNode("<elided len="+strconv.Itoa(fv.Len())+">"))
+		default:
+			savedHex := s.hexInts
+			s.hexInts = s.hexInts || tag.hex
+			s.forceMethods = tag.str
+			n.elems = append(n.elems, s.value(fv))
+			s.hexInts = savedHex
+			s.forceMethods = false
+		}
+		s.fieldPath = s.fieldPath[:len(s.fieldPath)-1]
+	}
+	return n
+}
+
+// jsonString returns s as a JSON string literal.  Invalid UTF-8 is replaced
+// with the Unicode replacement character.
+func jsonString(s string) string {
+	var buf strings.Builder
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r == '\n':
+			buf.WriteString(`\n`)
+		case r == '\r':
+			buf.WriteString(`\r`)
+		case r == '\t':
+			buf.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f || r == 0x2028 || r == 0x2029:
+			fmt.Fprintf(&buf, `\u%04x`, r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+// encoder writes nodes as JSON or YAML.
+type encoder struct {
+	w      io.Writer
+	indent string
+	cs     *ConfigState
+}
+
+// newline writes a newline followed by indentation for depth.
+func (e *encoder) newline(depth int) {
+	write(e.w, newlineBytes)
+	write(e.w, []byte(strings.Repeat(e.indent, depth)))
+}
+
+// annotated returns n wrapped in an object holding its type annotation, the
+// JSON representation of annotations.
+func annotated(n *node) *node {
+	if n.typ == "" {
+		return n
+	}
+	value := *n
+	value.typ = ""
+	return &node{
+		kind:  nodeMap,
+		keys:  []string{"type", "value"},
+		elems: []*node{stringNode(n.typ), &value},
+	}
+}
+
+// json writes n as JSON at the passed depth.
+func (e *encoder) json(n *node, depth int) {
+	n = annotated(n)
+	switch n.kind {
+	case nodeNull:
+		write(e.w, nullBytes)
+
+	case nodeBool, nodeNumber:
+		write(e.w, []byte(n.text))
+
+	case nodeString:
+		write(e.w, []byte(jsonString(n.text)))
+
+	case nodeList, nodeMap:
+		open, end := openBracketBytes, closeBracketBytes
+		if n.kind == nodeMap {
+			open, end = openBraceBytes, closeBraceBytes
+		}
+		write(e.w, open)
+		for i, elem := range n.elems {
+			if i > 0 {
+				write(e.w, commaBytes)
+			}
+			if !e.cs.Compact {
+				e.newline(depth + 1)
+			}
+			if n.kind == nodeMap {
+				write(e.w, []byte(jsonString(n.keys[i])))
+				write(e.w, colonBytes)
+				if !e.cs.Compact {
+					write(e.w, spaceBytes)
+				}
+			}
+			e.json(elem, depth+1)
+		}
+		if !e.cs.Compact && len(n.elems) > 0 {
+			e.newline(depth)
+		}
+		write(e.w, end)
+	}
+}
+
+// yamlPlain reports whether s can be written as a plain YAML scalar without
+// changing its meaning.  Plain scalars in flow style must not contain the
+// flow indicators.
+func yamlPlain(s string, flow bool) bool {
+	if flow && strings.ContainsAny(s, ",[]{}") {
+		return false
+	}
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return false
+	}
+	if strings.HasPrefix(s, "...") || strings.Contains(s, ": ") || strings.Contains(s, " #") ||
+		strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f || r == utf8.RuneError {
+			return false
+		}
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return false
+	}
+	if s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err != nil
+}
+
+// yamlString returns s as a YAML scalar, quoting it when needed.  JSON string
+// literals are valid double-quoted YAML scalars.
+func yamlString(s string, flow bool) string {
+	if yamlPlain(s, flow) {
+		return s
+	}
+	return jsonString(s)
+}
+
+// yamlScalar returns the YAML text of a scalar node.
+func yamlScalar(n *node, flow bool) string {
+	switch n.kind {
+	case nodeNull:
+		return string(nullBytes)
+	case nodeString:
+		return yamlString(n.text, flow)
+	}
+	return n.text
+}
+
+// isCollection reports whether n is a list or map with elements.
+func (n *node) isCollection() bool {
+	return (n.kind == nodeList || n.kind == nodeMap) && len(n.elems) > 0
+}
+
+// yamlFlow writes n in the YAML flow style used by the compact mode.  Type
+// annotations are written the same way as for JSON since flow style has no
+// room for comments.
+func (e *encoder) yamlFlow(n *node) {
+	n = annotated(n)
+	switch {
+	case n.kind == nodeList || n.kind == nodeMap:
+		open, end := openBracketBytes, closeBracketBytes
+		if n.kind == nodeMap {
+			open, end = openBraceBytes, closeBraceBytes
+		}
+		write(e.w, open)
+		for i, elem := range n.elems {
+			if i > 0 {
+				write(e.w, commaSpaceBytes)
+			}
+			if n.kind == nodeMap {
+				write(e.w, []byte(yamlString(n.keys[i], true)))
+				write(e.w, colonSpaceBytes)
+			}
+			e.yamlFlow(elem)
+		}
+		write(e.w, end)
+
+	default:
+		write(e.w, []byte(yamlScalar(n, true)))
+	}
+}
+
+// yaml writes n in YAML block style at the passed depth.  Type annotations
+// are written as comments.  When inline is set, n follows a list marker or
+// starts the document, so the first entry of a list or map is written on the
+// current line.  Otherwise n follows a map key.
+func (e *encoder) yaml(n *node, depth int, inline bool) {
+	if !n.isCollection() {
+		if !inline {
+			write(e.w, spaceBytes)
+		}
+		switch {
+		case n.kind == nodeList:
+			write(e.w, emptyListBytes)
+		case n.kind == nodeMap:
+			write(e.w, emptyMapBytes)
+		default:
+			write(e.w, []byte(yamlScalar(n, false)))
+		}
+		if n.typ != "" {
+			write(e.w, spaceBytes)
+			write(e.w, []byte(yamlCommentText+n.typ))
+		}
+		return
+	}
+
+	if n.typ != "" {
+		if !inline {
+			write(e.w, spaceBytes)
+		}
+		write(e.w, []byte(yamlCommentText+n.typ))
+		inline = false
+	}
+	for i, elem := range n.elems {
+		if i > 0 || !inline {
+			e.newline(depth)
+		}
+		if n.kind == nodeList {
+			write(e.w, dashSpaceBytes)
+			e.yaml(elem, depth+1, true)
+			continue
+		}
+		write(e.w, []byte(yamlString(n.keys[i], false)))
+		write(e.w, colonBytes)
+		e.yaml(elem, depth+1, false)
+	}
+}
+
+// fdumpStructured displays the passed arguments to w as JSON or YAML
+// documents according to the Format option.  YAML is always indented with
+// two spaces since it does not allow tabs and list entries are aligned with
+// the two character "- " marker.
+func fdumpStructured(cs *ConfigState, w io.Writer, a ...interface{}) {
+	for i, arg := range a {
+		s := structuredState{
+			pointers:  make(map[uintptr]int),
+			snapshots: make(map[reflect.Type]bool),
+			cs:        cs,
+		}
+		var n *node
+		if arg == nil {
+			n = s.annotate(&node{kind: nodeNull}, 0, interfaceType)
+		} else {
+			n = s.value(reflect.ValueOf(arg))
+		}
+
+		e := encoder{w: w, indent: cs.Indent, cs: cs}
+		switch {
+		case cs.Format == FormatJSON:
+			e.json(n, 0)
+		case cs.Compact:
+			e.yamlFlow(n)
+		default:
+			if i > 0 {
+				write(w, yamlDocBytes)
+			}
+			e.indent = yamlIndentText
+			e.yaml(n, 0, true)
+		}
+		write(w, newlineBytes)
+	}
+}
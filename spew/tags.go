@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"reflect"
+	"strings"
+)
+
+// tagKey is the struct tag key consulted for per-field options.
+const tagKey = "trace"
+
+// fieldTag holds the options parsed from the trace tag of a struct field.
+// The tag is a comma-separated list of the following options:
+//
+//	-          skip the field
+//	omitempty  skip the field when it holds a zero or empty value
+//	hex        display integers in hexadecimal
+//	len        display only the length of strings, slices, arrays, maps
+//	           and channels
+//	string     display the result of the String or Error method even
+//	           when DisableMethods is set
+//	name=NAME  display the field as NAME
+//	redact     display the field as <redacted len=N>
+type fieldTag struct {
+	skip      bool
+	omitEmpty bool
+	hex       bool
+	lenOnly   bool
+	str       bool
+	redact    bool
+	name      string
+}
+
+// parseFieldTag parses the comma-separated options of the trace tag of the
+// passed struct field.  Unknown options are ignored.
+func parseFieldTag(field reflect.StructField) fieldTag {
+	var tag fieldTag
+	for _, opt := range strings.Split(field.Tag.Get(tagKey), ",") {
+		opt = strings.TrimSpace(opt)
+		switch {
+		case opt == "-":
+			tag.skip = true
+		case opt == "omitempty":
+			tag.omitEmpty = true
+		case opt == "hex":
+			tag.hex = true
+		case opt == "len":
+			tag.lenOnly = true
+		case opt == "string":
+			tag.str = true
+		case opt == "redact":
+			tag.redact = true
+		case strings.HasPrefix(opt, "name="):
+			tag.name = strings.TrimPrefix(opt, "name=")
+		}
+	}
+	return tag
+}
+
+// displayName returns the name to display for the passed struct field.
+func (t fieldTag) displayName(field reflect.StructField) string {
+	if t.name != "" {
+		return t.name
+	}
+	return field.Name
+}
+
+// hasLen returns whether the built-in len function works with the passed
+// kind.
+func hasLen(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map, reflect.Chan:
+		return true
+	}
+	return false
+}
+
+// isEmptyValue returns whether v is a zero or empty value for the purposes
+// of the omitempty option: false, 0, nil pointers, interfaces, functions
+// and channels, empty strings, arrays, slices and maps, and structs whose
+// fields are all empty.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return v.Int() == 0
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Complex64, reflect.Complex128:
+		return v.Complex() == 0
+	case reflect.String, reflect.Array, reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return v.IsNil()
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !isEmptyValue(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
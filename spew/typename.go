@@ -0,0 +1,231 @@
+/*
+ * Copyright (c) 2013-2016 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// TypeNameMode selects how Dump and the custom formatter display type names.
+type TypeNameMode int
+
+const (
+	// TypeNameFull displays type names as reflect does, for example
+	// pkg.Map[github.com/x/y.Key,github.com/x/y.Value].  It is the
+	// default.
+	TypeNameFull TypeNameMode = iota
+
+	// TypeNameShort abbreviates the import paths within type names to
+	// package names, for example pkg.Map[y.Key,y.Value].  The package name
+	// is taken from the named types reachable from the displayed type, or
+	// assumed from the import path as goimports does when the package is
+	// not seen.
+	TypeNameShort
+
+	// TypeNameCompact abbreviates import paths like TypeNameShort and
+	// elides the type arguments of generic types, for example
+	// pkg.Map[...].
+	TypeNameCompact
+
+	// TypeNameBase displays only the base name of each type, without
+	// package qualifiers or type arguments, for example Map.
+	TypeNameBase
+)
+
+// typeName returns the name displayed for type t according to the TypeNames
+// option.  The empty interface is shown as any in compact mode, and type
+// names listed in the TypeAliases option are replaced by their aliases
+// wherever they appear.
+func (c *ConfigState) typeName(t reflect.Type) string {
+	name := t.String()
+	if c.Compact {
+		name = strings.Replace(name, "interface {}", "any", -1)
+	}
+	if len(c.TypeAliases) == 0 && c.TypeNames == TypeNameFull {
+		return name
+	}
+	if c.TypeNames == TypeNameShort || c.TypeNames == TypeNameCompact {
+		learnPkgNames(t)
+	}
+	return c.rewriteTypeName(name)
+}
+
+var (
+	// pkgNames maps the import paths of the packages seen by learnPkgNames
+	// to their package names.
+	pkgNames sync.Map
+
+	// learnedTypes holds the types already walked by learnPkgNames.
+	learnedTypes sync.Map
+)
+
+// learnPkgNames records the package names of the named types reachable from
+// t through its elements, keys, fields, and function signatures.  reflect
+// qualifies the type arguments of generic types with import paths, so these
+// names are needed to shorten them.
+func learnPkgNames(t reflect.Type) {
+	if _, done := learnedTypes.LoadOrStore(t, true); done {
+		return
+	}
+	if path := t.PkgPath(); path != "" && t.Name() != "" {
+		name := t.String()
+		if dot := strings.IndexByte(name, '.'); dot > 0 {
+			pkgNames.LoadOrStore(path, name[:dot])
+		}
+	}
+	switch t.Kind() {
+	case reflect.Array, reflect.Chan, reflect.Ptr, reflect.Slice:
+		learnPkgNames(t.Elem())
+	case reflect.Map:
+		learnPkgNames(t.Key())
+		learnPkgNames(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			learnPkgNames(t.Field(i).Type)
+		}
+	case reflect.Func:
+		for i := 0; i < t.NumIn(); i++ {
+			learnPkgNames(t.In(i))
+		}
+		for i := 0; i < t.NumOut(); i++ {
+			learnPkgNames(t.Out(i))
+		}
+	}
+}
+
+// pkgName returns the package name of the package with the passed import
+// path.  Packages not seen by learnPkgNames are assumed to be named after the
+// last element of the path, skipping a major version element such as v2, and
+// without any go- prefix or anything after the first character which may not
+// be part of an identifier, as goimports does.
+func pkgName(path string) string {
+	if name, ok := pkgNames.Load(path); ok {
+		return name.(string)
+	}
+	elems := strings.Split(path, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && len(name) > 1 && name[0] == 'v' &&
+		strings.Trim(name[1:], "0123456789") == "" {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexFunc(name, func(r rune) bool {
+		return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+// isTypeNameByte returns whether b may be part of an identifier, package
+// qualifier, or import path within a type name.
+func isTypeNameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' ||
+		b == '_' || b == '.' || b == '/' || b == '-' || b == '~' || b >= 0x80
+}
+
+// closingBracket returns the index just past the bracket which closes the one
+// at s[open].
+func closingBracket(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// rewriteTypeName applies the TypeAliases and TypeNames options to each
+// qualified identifier within the type name s, recursing into the type
+// arguments of generic types.
+func (c *ConfigState) rewriteTypeName(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if !isTypeNameByte(s[i]) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(s) && isTypeNameByte(s[j]) {
+			j++
+		}
+		ident := s[i:j]
+
+		// Type arguments directly follow the name of a generic type,
+		// unlike the key type following map.
+		args := ""
+		if j < len(s) && s[j] == '[' && ident != "map" {
+			end := closingBracket(s, j)
+			args, j = s[j:end], end
+		}
+		i = j
+
+		if alias, ok := c.TypeAliases[ident+args]; ok {
+			b.WriteString(alias)
+			continue
+		}
+		if alias, ok := c.TypeAliases[ident]; ok {
+			ident = alias
+		} else {
+			ident = shortenIdent(ident, c.TypeNames)
+		}
+		b.WriteString(ident)
+		if args == "" {
+			continue
+		}
+		switch c.TypeNames {
+		case TypeNameFull, TypeNameShort:
+			b.WriteByte('[')
+			b.WriteString(c.rewriteTypeName(args[1 : len(args)-1]))
+			b.WriteByte(']')
+		case TypeNameCompact:
+			b.WriteString("[...]")
+		}
+	}
+	return b.String()
+}
+
+// shortenIdent shortens a possibly qualified identifier, such as
+// github.com/x/y.Key or y.Key, according to mode.
+func shortenIdent(ident string, mode TypeNameMode) string {
+	switch mode {
+	case TypeNameShort, TypeNameCompact:
+		// The identifier follows the last dot, since import paths such
+		// as gopkg.in/yaml.v2 may hold dots themselves.  Identifiers
+		// qualified by a package name are left alone.
+		dot := strings.LastIndexByte(ident, '.')
+		if dot < 0 || !strings.ContainsAny(ident[:dot], "./") {
+			return ident
+		}
+		return pkgName(ident[:dot]) + ident[dot:]
+
+	case TypeNameBase:
+		return ident[strings.LastIndexByte(ident, '.')+1:]
+	}
+	return ident
+}
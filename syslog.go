@@ -0,0 +1,337 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package trace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Syslog severities, as defined by RFC 5424.
+const (
+	SeverityEmergency = iota
+	SeverityAlert
+	SeverityCritical
+	SeverityError
+	SeverityWarning
+	SeverityNotice
+	SeverityInfo
+	SeverityDebug
+)
+
+const (
+	// DefaultSyslogAddr is the local syslog socket.
+	DefaultSyslogAddr = "/dev/log"
+
+	// DefaultJournalAddr is the journald native protocol socket.
+	DefaultJournalAddr = "/run/systemd/journal/socket"
+
+	// FacilityUser is the syslog facility for user level messages,
+	// which is used by default.
+	FacilityUser = 1
+
+	// syslogTimeFormat is the RFC 5424 timestamp layout, which allows
+	// at most microsecond precision.
+	syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+	// syslogSDID is the structured data ID of the source location
+	// parameters, under the enterprise number reserved for
+	// documentation by RFC 5612.
+	syslogSDID = "trace@32473"
+)
+
+// DefaultSeverity maps trace levels to syslog severities: records
+// output by Print*() and the Dump functions are notices, level 1 records
+// are informational and records at higher levels are debug messages.
+func DefaultSeverity(level int) int {
+	switch {
+	case level <= 0:
+		return SeverityNotice
+	case level == 1:
+		return SeverityInfo
+	}
+	return SeverityDebug
+}
+
+// recordText returns the message or dump of r without the leader or a
+// trailing newline.
+func recordText(r *Record) string {
+	if r.Dump != "" {
+		return strings.TrimRight(r.Dump, "\n")
+	}
+	return strings.TrimRight(r.Message, " \t\n")
+}
+
+// datagramConn is a Unix datagram socket connection which is dialed on
+// first use and redialed once when a send fails, e.g. because the
+// daemon listening on the socket was restarted.
+type datagramConn struct {
+	addr string
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// send writes p as a single datagram.
+func (c *datagramConn) send(p []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	for retry := 0; retry < 2; retry++ {
+		if c.conn == nil {
+			if c.conn, err = net.Dial("unixgram", c.addr); err != nil {
+				c.conn = nil
+				continue
+			}
+		}
+		if _, err = c.conn.Write(p); err == nil {
+			return nil
+		}
+		_ = c.conn.Close()
+		c.conn = nil
+	}
+	return err
+}
+
+// close closes the connection if it is open.
+func (c *datagramConn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// SyslogSink is a Sink which sends records at or below Level to a local
+// syslog daemon as RFC 5424 messages. The source file, line and
+// function of each record are sent as structured data, e.g.
+//
+//	<13>1 2019-01-02T03:04:05.000000Z host app 42 - [trace@32473 file="main.go" line="12" func="main.main"] message
+type SyslogSink struct {
+	// Addr is the path of the syslog socket.
+	Addr string
+
+	// Level is the highest trace level sent.
+	Level int
+
+	// Facility is the syslog facility, FacilityUser by default.
+	Facility int
+
+	// AppName is the application name, the base name of the program
+	// by default.
+	AppName string
+
+	// Hostname is the host name, os.Hostname by default.
+	Hostname string
+
+	// Severity maps trace levels to syslog severities. If nil,
+	// DefaultSeverity is used.
+	Severity func(level int) int
+
+	conn     datagramConn
+	initOnce sync.Once
+}
+
+// NewSyslogSink returns a SyslogSink sending records at or below level
+// to the syslog socket at addr, or DefaultSyslogAddr if addr is empty.
+func NewSyslogSink(addr string, level int) *SyslogSink {
+	if addr == "" {
+		addr = DefaultSyslogAddr
+	}
+	return &SyslogSink{Addr: addr, Level: level, Facility: FacilityUser}
+}
+
+// Enabled implements Sink.
+func (s *SyslogSink) Enabled(level int) bool {
+	return level <= loadLevel(&s.Level)
+}
+
+func (s *SyslogSink) levelField() *int { return &s.Level }
+
+// WriteRecord implements Sink.
+func (s *SyslogSink) WriteRecord(r *Record) error {
+	s.initOnce.Do(func() {
+		s.conn.addr = s.Addr
+		if s.AppName == "" {
+			s.AppName = filepath.Base(os.Args[0])
+		}
+		if s.Hostname == "" {
+			s.Hostname, _ = os.Hostname()
+		}
+	})
+	return s.conn.send(s.format(r))
+}
+
+// format returns r as an RFC 5424 message.
+func (s *SyslogSink) format(r *Record) []byte {
+	severity := s.Severity
+	if severity == nil {
+		severity = DefaultSeverity
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %d - [%s file=\"%s\" line=\"%d\" func=\"%s\"]",
+		s.Facility*8+severity(r.Level),
+		r.Time.Format(syslogTimeFormat),
+		syslogHeader(s.Hostname),
+		syslogHeader(s.AppName),
+		os.Getpid(),
+		syslogSDID,
+		syslogParam(r.File),
+		r.Line,
+		syslogParam(r.Func))
+	if msg := recordText(r); msg != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(msg)
+	}
+	return buf.Bytes()
+}
+
+// Close closes the connection to the syslog socket. A later record
+// opens it again.
+func (s *SyslogSink) Close() error {
+	return s.conn.close()
+}
+
+// syslogHeader returns s as an RFC 5424 header field, which is "-" when
+// empty and printable ASCII without spaces otherwise.
+func syslogHeader(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// syslogParam escapes s for use as an RFC 5424 structured data
+// parameter value.
+func syslogParam(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(s)
+}
+
+// JournalSink is a Sink which sends records at or below Level to
+// journald using its native protocol. The source file, line and
+// function of each record are sent as the CODE_FILE, CODE_LINE and
+// CODE_FUNC fields, and the trace level as TRACE_LEVEL. Records larger
+// than the maximum datagram size of the socket are not sent.
+type JournalSink struct {
+	// Addr is the path of the journald socket.
+	Addr string
+
+	// Level is the highest trace level sent.
+	Level int
+
+	// Identifier is sent as SYSLOG_IDENTIFIER, the base name of the
+	// program by default.
+	Identifier string
+
+	// Severity maps trace levels to the syslog severities sent as
+	// PRIORITY. If nil, DefaultSeverity is used.
+	Severity func(level int) int
+
+	// Fields holds additional fields sent with every record. The
+	// names must be upper case letters, digits and underscores.
+	Fields map[string]string
+
+	conn     datagramConn
+	initOnce sync.Once
+}
+
+// NewJournalSink returns a JournalSink sending records at or below
+// level to the journald socket at addr, or DefaultJournalAddr if addr is
+// empty.
+func NewJournalSink(addr string, level int) *JournalSink {
+	if addr == "" {
+		addr = DefaultJournalAddr
+	}
+	return &JournalSink{Addr: addr, Level: level}
+}
+
+// Enabled implements Sink.
+func (s *JournalSink) Enabled(level int) bool {
+	return level <= loadLevel(&s.Level)
+}
+
+func (s *JournalSink) levelField() *int { return &s.Level }
+
+// WriteRecord implements Sink.
+func (s *JournalSink) WriteRecord(r *Record) error {
+	s.initOnce.Do(func() {
+		s.conn.addr = s.Addr
+		if s.Identifier == "" {
+			s.Identifier = filepath.Base(os.Args[0])
+		}
+	})
+	return s.conn.send(s.format(r))
+}
+
+// format returns r encoded in the journald native protocol.
+func (s *JournalSink) format(r *Record) []byte {
+	severity := s.Severity
+	if severity == nil {
+		severity = DefaultSeverity
+	}
+	var buf bytes.Buffer
+	journalField(&buf, "MESSAGE", recordText(r))
+	journalField(&buf, "PRIORITY", strconv.Itoa(severity(r.Level)))
+	journalField(&buf, "SYSLOG_IDENTIFIER", s.Identifier)
+	journalField(&buf, "CODE_FILE", r.File)
+	journalField(&buf, "CODE_LINE", strconv.Itoa(r.Line))
+	journalField(&buf, "CODE_FUNC", r.Func)
+	journalField(&buf, "TRACE_LEVEL", strconv.Itoa(r.Level))
+	names := make([]string, 0, len(s.Fields))
+	for name := range s.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		journalField(&buf, name, s.Fields[name])
+	}
+	return buf.Bytes()
+}
+
+// Close closes the connection to the journald socket. A later record
+// opens it again.
+func (s *JournalSink) Close() error {
+	return s.conn.close()
+}
+
+// journalField appends a field to buf in the journald native protocol.
+// Values holding newlines are sent as the name followed by the length
+// of the value as a 64 bit little endian integer and the value.
+func journalField(buf *bytes.Buffer, name, value string) {
+	if value == "" {
+		return
+	}
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
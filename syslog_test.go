@@ -0,0 +1,82 @@
+// Copyright 2019 Secure64 Software Corporation. All rights reserved.
+// Use of this source code is governed by a MIT-style license that can
+// be found in the LICENSE file.
+
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package trace_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/apatters/go-trace"
+	"github.com/stretchr/testify/assert"
+)
+
+// listenUnixgram returns a Unix datagram socket standing in for a
+// logging daemon, and a function reading the next datagram from it.
+func listenUnixgram(t *testing.T, dir, name string) (string, func() string) {
+	addr := filepath.Join(dir, name)
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return addr, func() string {
+		buf := make([]byte, 65536)
+		n, err := conn.Read(buf)
+		assert.NoError(t, err)
+		return string(buf[:n])
+	}
+}
+
+func TestSyslogSinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trace")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	syslogAddr, readSyslog := listenUnixgram(t, dir, "log")
+	journalAddr, readJournal := listenUnixgram(t, dir, "journal")
+	syslogSink := trace.NewSyslogSink(syslogAddr, 2)
+	syslogSink.AppName = "app"
+	syslogSink.Hostname = "host"
+	journalSink := trace.NewJournalSink(journalAddr, 2)
+	journalSink.Identifier = "app"
+	journalSink.Fields = map[string]string{"UNIT_ROLE": "test"}
+	trace.SetSinks(syslogSink, journalSink)
+	defer trace.SetSinks()
+
+	trace.PrintfLevel(1, "say %q", "hi]")
+	out := readSyslog()
+	t.Logf("out = %s", out)
+	assert.Regexp(t, `^<14>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}(Z|[+-]\d\d:\d\d) host app \d+ - `+
+		`\[trace@32473 file="syslog_test.go" line="\d+" func="[^"]+\.TestSyslogSinks"\] say "hi]"$`, out)
+	out = readJournal()
+	t.Logf("out = %q", out)
+	assert.Regexp(t, `^MESSAGE=say "hi]"\nPRIORITY=6\nSYSLOG_IDENTIFIER=app\n`+
+		`CODE_FILE=syslog_test.go\nCODE_LINE=\d+\nCODE_FUNC=[^\n]+\.TestSyslogSinks\n`+
+		`TRACE_LEVEL=1\nUNIT_ROLE=test\n$`, out)
+
+	trace.Dump([]int{1})
+	out = readSyslog()
+	t.Logf("out = %s", out)
+	assert.Regexp(t, `^<13>1 .*\] `+regexp.QuoteMeta("([]int) (len=1 cap=1) {\n\t(int) 1\n}")+`$`, out)
+	out = readJournal()
+	t.Logf("out = %q", out)
+	msg := "([]int) (len=1 cap=1) {\n\t(int) 1\n}"
+	assert.Equal(t, "MESSAGE\n"+string([]byte{byte(len(msg)), 0, 0, 0, 0, 0, 0, 0})+msg+"\nPRIORITY=5\n",
+		out[:len("MESSAGE\n")+8+len(msg)+len("\nPRIORITY=5\n")])
+
+	trace.PrintLevel(3, "not sent")
+	trace.Print("sent")
+	assert.Regexp(t, `\] sent$`, readSyslog())
+	assert.Regexp(t, `^MESSAGE=sent\n`, readJournal())
+	assert.NoError(t, syslogSink.Close())
+	assert.NoError(t, journalSink.Close())
+}
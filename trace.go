@@ -12,16 +12,77 @@ There is also a Dump() function used to dump complex data structures
 in an easy to understand format. The Dump function uses a modified
 version of Dave Collins' https://github.com/davecgh/go-spew package to
 pretty-print data structures.
+
+Common standard library types such as time.Time, big.Int, net.IP and
+sync.Mutex are dumped in a readable form rather than as their internal
+fields (see spew.Renderer).
+
+Values shared between goroutines can be dumped with DumpLocked while
+holding the lock guarding them, or by implementing spew.Snapshotter to
+return a consistent copy for Dump to walk instead.
+
+Output is colored with ANSI escape sequences when Writer is a terminal
+and the NO_COLOR environment variable is not set. Set Color to
+ColorAlways or ColorNever to override the detection and ColorTheme to
+change the colors.
+
+Trace output can be sent to a file which is rotated by size or age by
+assigning a FileSink, configured in code or with FileSinkFromEnv, to
+Writer.
+
+A RingBuffer assigned to Writer keeps only the most recent trace
+records in memory, and writes them out when flushed on demand, when
+the program panics (see FlushOnPanic) or when a signal is received
+(see FlushOnSignal).
+
+Records can be sent to several destinations at once by adding Sinks
+with AddSink. Each sink has its own level, filter and encoding, e.g. a
+WriterSink writing text at trace level 1 to os.Stdout and another
+writing JSON (see JSONEncoder) at every level to a FileSink. Writer and
+TraceLevel are only used while no sinks have been added. On Unix
+systems, SyslogSink and JournalSink send records to the local syslog
+daemon and to journald with the source location as structured fields.
+A NetSink streams numbered records to a collector over TCP, UDP or a
+Unix socket, buffering them while reconnecting.
+
+The records of a subsystem can be tagged by tracing with the methods
+of a Category, e.g. trace.NewCategory("net").Printf(...), so sinks and
+the gotrace command can filter them by Record.Category.
+
+The level of individual source files can be set with SetVModule, like
+glog's -vmodule flag, and the level of categories with
+SetCategoryLevel. TraceLevel, the levels of the sinks, the categories
+and the source files can be changed without a restart with
+SetTraceLevel, SetSinkLevel, SetCategoryLevel and SetVModule, or over
+HTTP by mounting Handler on a debug mux. In programs without an HTTP server, HandleSignals
+raises and lowers them on SIGUSR1 and SIGUSR2.
+
+An AsyncWriter queues records for a slow destination and writes them
+from a background goroutine, with a bounded queue which blocks or
+drops records when full (see OverflowPolicy). Flush or Close it before
+exiting so the queued records are written.
+
+Struct fields and string keyed map entries whose names look like
+credentials (see spew.DefaultRedactFields), or which are tagged with
+`trace:"redact"`, are output as <redacted len=N> by the Dump
+functions. The Print*() functions format a copy of their arguments in
+which such strings are replaced by <redacted len=N> and other such
+values by their zero value, so nothing else about their output changes
+and the values are redacted whatever the verb.
 */
 package trace
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"reflect"
 	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/apatters/go-trace/spew"
 )
@@ -43,7 +104,9 @@ var (
 	// Writer is used for trace output.
 	Writer io.Writer = os.Stdout
 
-	// TraceLevel is used to control output of Print*Level functions.
+	// TraceLevel is used to control output of Print*Level
+	// functions. Use SetTraceLevel to change it while other
+	// goroutines are tracing.
 	TraceLevel int
 )
 
@@ -55,131 +118,167 @@ func init() {
 	SpewCS.DisableMethods = true
 	SpewCS.SortKeys = true
 	SpewCS.SpewKeys = true
+	SpewCS.RedactFields = spew.DefaultRedactFields
+	SpewCS.Renderers = spew.RenderAll
 }
 
-// fprint wraps output to the io.Writer. The go test command requires output go directly
-// to os.Stdout.
-func fprint(w io.Writer, leader string, a ...interface{}) (n int, err error) {
-	var msg string
-
-	if len(a) == 0 {
-		msg = strings.TrimRight(leader, " \t\n")
-	} else {
-		msg = leader + fmt.Sprint(a...)
-	}
-	msg = strings.TrimRight(msg, " \t\n")
-
-	switch v := w.(type) {
-	case *os.File:
-		if v.Name() == stdoutName {
-			return fmt.Println(msg)
+// redactArgs replaces any argument which may contain redacted values,
+// according to SpewCS, with a copy holding the redaction marker in
+// their place, so they are redacted whatever the verb. Errors and
+// fmt.Stringers are left alone so their methods are used.
+func redactArgs(args []interface{}) []interface{} {
+	var redacted []interface{}
+	for i, arg := range args {
+		switch arg.(type) {
+		case nil, error, fmt.Stringer:
+			continue
+		}
+		if !SpewCS.MayRedact(reflect.TypeOf(arg)) {
+			continue
+		}
+		if redacted == nil {
+			redacted = make([]interface{}, len(args))
+			copy(redacted, args)
 		}
+		redacted[i] = SpewCS.Redacted(arg)
 	}
-
-	return fmt.Fprintln(Writer, msg)
+	if redacted == nil {
+		return args
+	}
+	return redacted
 }
 
-// fprintln wraps output to the io.Writer. The go test command
-// requires output go directly to os.Stdout.
-func fprintln(w io.Writer, leader string, a ...interface{}) (n int, err error) {
-	var msg string
-
-	if len(a) == 0 {
-		msg = strings.TrimRight(leader, " \t\n")
-	} else {
-		msg = leader + fmt.Sprintln(a...)
+// message returns the text of a Print*() call after the leader, with
+// any args which may hold redacted values formatted by SpewCS.
+func message(sprint func(a ...interface{}) string, args []interface{}) string {
+	if len(args) == 0 {
+		return ""
 	}
-	msg = strings.TrimRight(msg, " \t\n")
+	return sprint(redactArgs(args)...)
+}
 
-	switch v := w.(type) {
-	case *os.File:
-		if v.Name() == stdoutName {
-			return fmt.Println(msg)
-		}
-	default:
+// newRecord returns a record for a trace function call at level from
+// the passed program counter, source file and line.
+func newRecord(level int, pc uintptr, filename string, line int) *Record {
+	r := &Record{
+		Time:  time.Now(),
+		Level: level,
+		File:  path.Base(filename),
+		Line:  line,
+	}
+	if f := runtime.FuncForPC(pc); f != nil {
+		r.Func = f.Name()
 	}
+	return r
+}
 
-	return fmt.Fprintln(Writer, msg)
+// printRecord emits a record holding msg.
+func printRecord(level int, pc uintptr, filename string, line int, msg string) {
+	r := newRecord(level, pc, filename, line)
+	r.Message = msg
+	emit(r)
 }
 
-// fprintf wraps output to the io.Writer. The go test command requires
-// output go directly to os.Stdout.
-func fprintf(w io.Writer, leader string, format string, a ...interface{}) (n int, err error) {
-	var msg string
+// dumpRecord emits a record holding the output of dump.
+func dumpRecord(pc uintptr, filename string, line int, values []interface{}, dump func(cs *spew.ConfigState, w io.Writer)) {
+	emit(newDumpRecord(pc, filename, line, values, dump))
+}
 
-	if len(a) == 0 {
-		msg = strings.TrimRight(leader, " \t\n")
-	} else {
-		msg = leader + fmt.Sprintf(format, a...)
+// newDumpRecord returns a record holding the output of dump, which is
+// rendered with SpewCS and again with the colored configuration for
+// sinks which color their output.
+func newDumpRecord(pc uintptr, filename string, line int, values []interface{}, dump func(cs *spew.ConfigState, w io.Writer)) *Record {
+	r := newRecord(0, pc, filename, line)
+	r.Values = values
+	r.render = func(cs *spew.ConfigState) string {
+		var buf bytes.Buffer
+		dump(cs, &buf)
+		return buf.String()
 	}
-	msg = strings.TrimRight(msg, " \t\n")
+	r.Dump = r.render(SpewCS)
+	return r
+}
 
+// fwrite wraps output of preformatted text to the io.Writer. The go
+// test command requires output go directly to os.Stdout.
+func fwrite(w io.Writer, p []byte) (n int, err error) {
 	switch v := w.(type) {
 	case *os.File:
 		if v.Name() == stdoutName {
-			return fmt.Println(msg)
+			return fmt.Printf("%s", p)
 		}
 	}
 
-	return fmt.Fprintln(Writer, msg)
+	return w.Write(p)
 }
 
-func leader(filename string, line int) string {
-	return fmt.Sprintf("%s%s:%d ", Leader, path.Base(filename), line)
+// formatLeader returns the leader, source file name, and source line
+// number, colored with ColorTheme when color is set.
+func formatLeader(filename string, line int, color bool) string {
+	if color {
+		return colorize(ColorTheme.Leader, Leader) +
+			colorize(ColorTheme.FileLine, fmt.Sprintf("%s:%d", filename, line)) + " "
+	}
+	return fmt.Sprintf("%s%s:%d ", Leader, filename, line)
 }
 
 // Print outputs the leader, source file name, and source line number
 // followed by any args in a similar manner as fmt.Print.
 func Print(args ...interface{}) {
-	_, filename, line, _ := runtime.Caller(1)
-	fprint(Writer, leader(filename, line), args...)
+	pc, filename, line, _ := runtime.Caller(1)
+	printRecord(0, pc, filename, line, message(fmt.Sprint, args))
 }
 
 // Print outputs the leader, source file name, and source line number
 // followed by any args in a similar manner as fmt.Println.
 func Println(args ...interface{}) {
-	_, filename, line, _ := runtime.Caller(1)
-	_, _ = fprintln(Writer, leader(filename, line), args...)
+	pc, filename, line, _ := runtime.Caller(1)
+	printRecord(0, pc, filename, line, message(fmt.Sprintln, args))
 }
 
 // Print outputs the leader, source file name, and source line number
 // followed by any args in a similar manner as fmt.Printf. A trailing
 // newline is also output.
 func Printf(format string, args ...interface{}) {
-	_, filename, line, _ := runtime.Caller(1)
-	_, _ = fprintf(Writer, leader(filename, line), format, args...)
-
+	pc, filename, line, _ := runtime.Caller(1)
+	printRecord(0, pc, filename, line, message(func(a ...interface{}) string {
+		return fmt.Sprintf(format, a...)
+	}, args))
 }
 
 // PrintLevel operates identically to Print except no output is done
-// if level is greater that the current trace level (TraceLevel).
+// if level is greater that the current trace level (TraceLevel), or
+// when sinks have been added, if no sink wants records at level. A
+// level set for the source file with SetVModule replaces both.
 func PrintLevel(level int, args ...interface{}) {
-	if level > TraceLevel {
-		return
+	if r := levelRecord(level, ""); r != nil {
+		r.Message = message(fmt.Sprint, args)
+		emit(r)
 	}
-	_, filename, line, _ := runtime.Caller(1)
-	_, _ = fprint(Writer, leader(filename, line), args...)
-
 }
 
 // PrintlnLevel operates identically to Println except no output is
-// done if level is greater that the current trace level (TraceLevel).
+// done if level is greater that the current trace level (TraceLevel),
+// or when sinks have been added, if no sink wants records at level. A
+// level set for the source file with SetVModule replaces both.
 func PrintlnLevel(level int, args ...interface{}) {
-	if level > TraceLevel {
-		return
+	if r := levelRecord(level, ""); r != nil {
+		r.Message = message(fmt.Sprintln, args)
+		emit(r)
 	}
-	_, filename, line, _ := runtime.Caller(1)
-	_, _ = fprintln(Writer, leader(filename, line), args...)
 }
 
 // PrintfLevel operates identically to Printf except no output is done
-// if level is greater that the current trace level (TraceLevel).
+// if level is greater that the current trace level (TraceLevel), or
+// when sinks have been added, if no sink wants records at level. A
+// level set for the source file with SetVModule replaces both.
 func PrintfLevel(level int, format string, args ...interface{}) {
-	if level > TraceLevel {
-		return
+	if r := levelRecord(level, ""); r != nil {
+		r.Message = message(func(a ...interface{}) string {
+			return fmt.Sprintf(format, a...)
+		}, args)
+		emit(r)
 	}
-	_, filename, line, _ := runtime.Caller(1)
-	_, _ = fprintf(Writer, leader(filename, line), format, args...)
 }
 
 // Dump() outputs the leader, source file name, and source line number
@@ -189,7 +288,103 @@ func PrintfLevel(level int, format string, args ...interface{}) {
 // https://github.com/davecgh/go-spew#configuration-options for
 // details.
 func Dump(args ...interface{}) {
-	_, filename, line, _ := runtime.Caller(1)
-	_, _ = fprintln(Writer, leader(filename, line))
-	SpewCS.Fdump(Writer, args...)
+	pc, filename, line, _ := runtime.Caller(1)
+	dumpRecord(pc, filename, line, args, func(cs *spew.ConfigState, w io.Writer) {
+		cs.Fdump(w, args...)
+	})
+}
+
+// DumpLocked outputs the same as Dump while holding locker, so values
+// guarded by locker can be dumped without racing with the goroutines
+// changing them. Pass the RLocker of a sync.RWMutex to hold only its
+// read lock. The lock is released before the dump is written to Writer.
+// The JSONEncoder outputs the dump as the message, since args may not be
+// read again once the lock is released.
+func DumpLocked(locker sync.Locker, args ...interface{}) {
+	pc, filename, line, _ := runtime.Caller(1)
+	r := newRecord(0, pc, filename, line)
+	cs := dumpConfig()
+	var colored string
+	func() {
+		locker.Lock()
+		defer locker.Unlock()
+		r.Dump = SpewCS.Sdump(args...)
+		colored = r.Dump
+		if cs != SpewCS {
+			colored = cs.Sdump(args...)
+		}
+	}()
+	r.render = func(*spew.ConfigState) string {
+		return colored
+	}
+	emit(r)
+}
+
+// DumpLine outputs the leader, source file name, and source line number
+// followed by a single-line dump of each arg. The dump shows the same
+// types, lengths and pointer chains as Dump but keeps each trace record
+// on one line, which suits line-oriented logs.
+func DumpLine(args ...interface{}) {
+	pc, filename, line, _ := runtime.Caller(1)
+	r := newRecord(0, pc, filename, line)
+	r.Values = args
+	r.render = func(cs *spew.ConfigState) string {
+		lineCS := *cs
+		lineCS.Compact = true
+		dumps := make([]string, len(args))
+		for i, arg := range args {
+			dumps[i] = strings.TrimRight(lineCS.Sdump(arg), "\n")
+		}
+		return strings.Join(dumps, " ")
+	}
+	r.Message = r.render(SpewCS)
+	emit(r)
+}
+
+// DumpGo outputs the leader, source file name, and source line number
+// followed by any args formatted as Go composite literals. The output
+// can be pasted into a test case and compiled. Unexported fields are
+// omitted with a comment noting each omission. The JSONEncoder outputs
+// the Go literals as the message rather than encoding args as JSON.
+func DumpGo(args ...interface{}) {
+	pc, filename, line, _ := runtime.Caller(1)
+	r := newRecord(0, pc, filename, line)
+	r.Dump = SpewCS.SdumpGo(args...)
+	emit(r)
+}
+
+// DumpDiff outputs the leader, source file name, and source line
+// number followed by the paths at which a and b differ, along with the
+// old and new values at each path. The JSONEncoder outputs the
+// differences as the message.
+func DumpDiff(a, b interface{}) {
+	pc, filename, line, _ := runtime.Caller(1)
+	dumpRecord(pc, filename, line, nil, func(cs *spew.ConfigState, w io.Writer) {
+		cs.Fdiff(w, a, b)
+	})
+}
+
+// DumpPath outputs the leader, source file name, and source line
+// number followed by a pretty-printed version of the sub-value of v at
+// path, e.g. "Conn.State.Peers[0]" or `Env["HOME"]`. Pointers and
+// interfaces along the path are followed. If the path is malformed or
+// does not exist in v, the error is output after the leader instead.
+func DumpPath(v interface{}, path string) {
+	pc, filename, line, _ := runtime.Caller(1)
+	var buf bytes.Buffer
+	if err := SpewCS.FdumpPath(&buf, v, path); err != nil {
+		printRecord(0, pc, filename, line, fmt.Sprint(err))
+		return
+	}
+	r := newRecord(0, pc, filename, line)
+	r.Dump = buf.String()
+	if sel, err := SpewCS.Select(v, path); err == nil {
+		r.Values = []interface{}{sel}
+	}
+	r.render = func(cs *spew.ConfigState) string {
+		buf.Reset()
+		_ = cs.FdumpPath(&buf, v, path)
+		return buf.String()
+	}
+	emit(r)
 }